@@ -0,0 +1,193 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"laptudirm.com/x/mash/pkg/parser"
+	"laptudirm.com/x/mash/pkg/printer"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+// errSyntax is returned by format if the source contains syntax errors,
+// which have already been reported.
+var errSyntax = errors.New("syntax errors")
+
+// runFmt executes the fmt subcommand with the command line arguments args
+// and returns the exit status of mash.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write result to the source file instead of standard output")
+	diff := flags.Bool("d", false, "display diffs instead of rewriting files")
+
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mash fmt [-w] [-d] [files...]")
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "mash: cannot use -w with standard input")
+			return exitSyntax
+		}
+
+		if err := formatFile("<stdin>", os.Stdin, false, *diff); err != nil {
+			return exitSyntax
+		}
+
+		return 0
+	}
+
+	status := 0
+	for _, name := range flags.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "mash: %s\n", err)
+			status = exitSyntax
+			continue
+		}
+
+		err = formatFile(name, f, *write, *diff)
+		f.Close()
+
+		if err != nil {
+			status = exitSyntax
+		}
+	}
+
+	return status
+}
+
+// formatFile formats the source read from r, whose file name is name. The
+// result is written to the standard output, unless write is set, in which
+// case it is written back to the file, or diff is set, in which case the
+// diff between the source and the result is written instead. Any errors
+// are reported on the standard error.
+func formatFile(name string, r io.Reader, write, diff bool) error {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mash: %s\n", err)
+		return err
+	}
+
+	res, err := format(name, src)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(src, res) && (write || diff) {
+		return nil
+	}
+
+	if write {
+		info, err := os.Stat(name)
+		if err == nil {
+			err = os.WriteFile(name, res, info.Mode().Perm())
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "mash: %s\n", err)
+			return err
+		}
+	}
+
+	if diff {
+		d, err := diffSource(name, src, res)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "mash: computing diff: %s\n", err)
+			return err
+		}
+
+		os.Stdout.Write(d)
+	}
+
+	if !write && !diff {
+		os.Stdout.Write(res)
+	}
+
+	return nil
+}
+
+// format parses the source src, whose file name is name, and returns it
+// formatted in the canonical mash style.
+func format(name string, src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	program, err := parser.Parse(fset, name, string(src), parser.ParseComments)
+	if err != nil {
+		report(err)
+		return nil, errSyntax
+	}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, program); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// diffSource returns the unified diff between the source src and it's
+// formatted version res, using the system's diff command.
+func diffSource(name string, src, res []byte) ([]byte, error) {
+	orig, err := writeTemp(src)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(orig)
+
+	formatted, err := writeTemp(res)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(formatted)
+
+	d, err := exec.Command("diff", "-u", "--label", name+".orig", "--label", name, orig, formatted).Output()
+
+	// diff exits with status 1 if the files differ
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		err = nil
+	}
+
+	return d, err
+}
+
+// writeTemp writes data to a new temporary file and returns it's name.
+func writeTemp(data []byte) (string, error) {
+	f, err := os.CreateTemp("", "mash-fmt")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
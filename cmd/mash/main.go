@@ -0,0 +1,160 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command mash executes mash programs.
+//
+// Usage:
+//
+//	mash [file [arguments...]]
+//	mash -c source [arguments...]
+//	mash fmt [-w] [-d] [files...]
+//
+// If no file is provided, the program is read from the standard input. If
+// the standard input is a terminal, an interactive session is started.
+// The arguments are provided to the program as the args array.
+//
+// The fmt subcommand formats mash source files in the canonical style. By
+// default, the formatted source is written to the standard output. The -w
+// flag writes it back to the source file instead, and the -d flag prints
+// a diff of the changes. Without files, the standard input is formatted.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"laptudirm.com/x/mash/pkg/diagnostics"
+	"laptudirm.com/x/mash/pkg/fold"
+	"laptudirm.com/x/mash/pkg/interp"
+	"laptudirm.com/x/mash/pkg/parser"
+)
+
+// Exit statuses of mash which are not the exit status of the program.
+const (
+	exitError  = 1 // runtime error
+	exitSyntax = 2 // syntax error or invalid usage
+)
+
+var command = flag.String("c", "", "execute the program `source`")
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		os.Exit(runFmt(os.Args[2:]))
+	}
+
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mash [file [arguments...]]")
+		fmt.Fprintln(os.Stderr, "       mash -c source [arguments...]")
+		fmt.Fprintln(os.Stderr, "       mash fmt [-w] [-d] [files...]")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+	os.Exit(run(flag.Args()))
+}
+
+// run executes the program specified by the command line arguments args
+// and returns the exit status of mash.
+func run(args []string) int {
+	var name, src string
+
+	switch {
+	case *command != "":
+		name, src = "-c", *command
+	case len(args) > 0:
+		b, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "mash: %s\n", err)
+			return exitSyntax
+		}
+
+		name, src = args[0], string(b)
+		args = args[1:]
+	case isTerminal(os.Stdin):
+		return repl(newInterpreter(args))
+	default:
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "mash: %s\n", err)
+			return exitSyntax
+		}
+
+		name, src = "<stdin>", string(b)
+	}
+
+	return execute(newInterpreter(args), name, src)
+}
+
+// isTerminal reports whether the file f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// newInterpreter creates a new interpreter with the arguments args defined
+// as the args array in it's global scope.
+func newInterpreter(args []string) *interp.Interpreter {
+	argv := make([]interp.Value, len(args))
+	for i, arg := range args {
+		argv[i] = interp.String(arg)
+	}
+
+	in := interp.New()
+	in.Define("args", &interp.Array{Elements: argv})
+	return in
+}
+
+// execute runs the program src, whose file name is name, and returns it's
+// exit status.
+func execute(in *interp.Interpreter, name, src string) int {
+	program, err := parser.Parse(in.Fset, name, src, 0)
+	if err != nil {
+		report(err)
+		return exitSyntax
+	}
+
+	// constant expressions which can't be evaluated are left as is, and
+	// fail only if they are run
+	fold.Fold(in.Fset, program)
+
+	err = in.Run(program)
+
+	var exit *interp.ExitError
+	switch {
+	case errors.As(err, &exit):
+		return exit.Status
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	default:
+		return in.Status()
+	}
+}
+
+// report reports the errors err from parsing and folding a file on the
+// standard error, one diagnostic per line in the format file:line:col:
+// message.
+func report(err error) {
+	list, ok := err.(diagnostics.ErrorList)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "mash: %s\n", err)
+		return
+	}
+
+	for _, e := range list {
+		fmt.Fprintln(os.Stderr, e)
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/fold"
+	"laptudirm.com/x/mash/pkg/interp"
+	"laptudirm.com/x/mash/pkg/lexer"
+	"laptudirm.com/x/mash/pkg/parser"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+// Prompts displayed by the read-eval-print loop.
+const (
+	prompt             = "mash> "
+	continuationPrompt = "....> "
+)
+
+// repl runs a read-eval-print loop, which reads statements from the
+// standard input and executes them using in, until EOF is reached or the
+// exit builtin is executed. It returns the exit status of the last command.
+func repl(in *interp.Interpreter) int {
+	scanner := bufio.NewScanner(os.Stdin)
+
+	// src accumulates lines until they form complete statements
+	var src strings.Builder
+
+	for {
+		if src.Len() == 0 {
+			fmt.Fprint(os.Stderr, prompt)
+		} else {
+			fmt.Fprint(os.Stderr, continuationPrompt)
+		}
+
+		if !scanner.Scan() {
+			// report errors in any incomplete input
+			if src.Len() > 0 {
+				if status, exited := evaluate(in, src.String()); exited {
+					return status
+				}
+			}
+
+			fmt.Fprintln(os.Stderr)
+			return in.Status()
+		}
+
+		src.WriteString(scanner.Text())
+		src.WriteByte('\n')
+
+		if incomplete(src.String()) {
+			continue
+		}
+
+		if status, exited := evaluate(in, src.String()); exited {
+			return status
+		}
+
+		src.Reset()
+	}
+}
+
+// incomplete reports whether src is an incomplete program which should be
+// continued on the next line. A program is incomplete if it has unclosed
+// blocks, parentheses, brackets or command substitutions, unterminated
+// strings, or ends with an infix operator or a keyword which requires more
+// input, like a trailing && or |.
+func incomplete(src string) bool {
+	var unterminated bool
+	var depth int
+	var last token.Type
+
+	// command reports whether the last top-level item is a command, in
+	// which case a trailing & runs it in the background
+	var command bool
+	start := true
+
+	file := token.NewFileSet().AddFile("<stdin>", src)
+
+	s := lexer.NewScanner(file, func(_ token.Position, err error) {
+		if err == lexer.ErrEOF {
+			unterminated = true
+		}
+	})
+
+	for tok := s.Next(); tok.Type != token.Eof; tok = s.Next() {
+		switch tok.Type {
+		case token.Semicolon:
+			// inserted semicolons are not significant
+			start = depth == 0
+			continue
+		case token.Comment:
+			continue
+		}
+
+		if start {
+			// statements start with a keyword
+			command, start = !tok.Type.IsKeyword(), false
+		}
+
+		switch tok.Type {
+		case token.LeftBrace, token.LeftParen, token.LeftBrack,
+			token.Subst, token.SubstResult:
+			depth++
+		case token.RightBrace, token.RightParen, token.RightBrack:
+			depth--
+		}
+
+		last = tok.Type
+	}
+
+	switch {
+	case unterminated, depth > 0:
+		return true
+	case last.IsKeyword():
+		return !last.InsertSemi()
+	case last == token.And && command:
+		return false
+	default:
+		return infix(last)
+	}
+}
+
+// infix reports whether t is an infix operator, which requires a right
+// operand on the next line.
+func infix(t token.Type) bool {
+	switch t {
+	case token.Addition, token.Subtraction, token.Multiplication,
+		token.Quotient, token.Remainder,
+		token.And, token.Or, token.Xor,
+		token.ShiftLeft, token.ShiftRight, token.AndNot,
+		token.AdditionAssign, token.SubtractionAssign,
+		token.MultiplicationAssign, token.QuotientAssign,
+		token.RemainderAssign,
+		token.AndAssign, token.OrAssign, token.XorAssign,
+		token.ShiftLeftAssign, token.ShiftRightAssign, token.AndNotAssign,
+		token.LogicalAnd, token.LogicalOr,
+		token.Equal, token.LessThan, token.GreaterThan,
+		token.Assign, token.Define,
+		token.NotEqual, token.LessThanEqual, token.GreaterThanEqual,
+		token.Comma, token.Period:
+		return true
+	default:
+		return false
+	}
+}
+
+// evaluate executes the statements of src using in. The values of let
+// statements are printed on the standard output. It reports whether the
+// exit builtin was executed, along with the exit status it provided.
+func evaluate(in *interp.Interpreter, src string) (int, bool) {
+	program, err := parser.Parse(in.Fset, "<stdin>", src, 0)
+	if err != nil {
+		report(err)
+		return 0, false
+	}
+
+	// constant expressions which can't be evaluated are left as is, and
+	// fail only if they are run
+	fold.Fold(in.Fset, program)
+
+	for _, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok {
+			v, err := in.Eval(let.Expression)
+			if err != nil {
+				return exited(err)
+			}
+
+			if _, ok := v.(interp.Nil); !ok {
+				fmt.Println(interp.Inspect(v))
+			}
+
+			continue
+		}
+
+		err := in.Run(&ast.Program{
+			Statements: []ast.Statement{stmt},
+		})
+
+		if err != nil {
+			return exited(err)
+		}
+	}
+
+	return 0, false
+}
+
+// exited reports the runtime error err on the standard error, unless it is
+// an *interp.ExitError, in which case it's exit status is returned.
+func exited(err error) (int, bool) {
+	var exit *interp.ExitError
+	if errors.As(err, &exit) {
+		return exit.Status, true
+	}
+
+	fmt.Fprintln(os.Stderr, err)
+	return 0, false
+}
@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"echo a\n", false},
+		{"let x = 1\n", false},
+		{"let x = 1 +\n", true},
+		{"let x = a ||\n", true},
+		{"let x = a.\n", true},
+		{"let x = f(1,\n", true},
+		{"let x = [1,\n", true},
+		{"let x = !a\n", false},
+		{"let x = 'a'\n", false},
+		{"let x = 'a\n", true},
+		{"let x = \"a\n", true},
+		{"if x {\n", true},
+		{"if x {\necho a\n}\n", false},
+		{"if\n", true},
+		{"func f(\n", true},
+		{"return\n", false},
+		{"echo a |\n", true},
+		{"echo a &&\n", true},
+		{"echo a ||\n", true},
+		{"echo a | cat\n", false},
+		{"(echo a\n", true},
+		{"(echo a)\n", false},
+		{"echo a # b +\n", false},
+		{"echo a >out\n", false},
+		{"echo a &\n", false},
+		{"sleep 1 &\necho a &\n", false},
+		{"let x = 1 &\n", true},
+		{"let x = 1\necho a &\n", false},
+		{"echo a\nlet x = 1 &\n", true},
+		{"echo $(pwd\n", true},
+		{"echo $?(pwd\n", true},
+		{"let x = $(pwd\n", true},
+		{"let x = $(pwd)\n", false},
+		{"let x = $(echo a &\n", true},
+		{"echo $(echo a) &\n", false},
+	}
+
+	for _, test := range tests {
+		if got := incomplete(test.src); got != test.want {
+			t.Errorf("incomplete(%q) = %v, expected %v", test.src, got, test.want)
+		}
+	}
+}
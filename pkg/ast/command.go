@@ -67,3 +67,168 @@ type LiteralCommand struct {
 
 func (l *LiteralCommand) Node()    {}
 func (l *LiteralCommand) Command() {}
+
+// SubshellCommand node represents a list of statements which are executed
+// as a command, in a subshell whose variables and working directory are
+// isolated from the enclosing program. The Redirects, which are Redirect
+// and Heredoc nodes, apply to the whole subshell.
+type SubshellCommand struct {
+	Opening    token.Token
+	Statements []Statement
+	Closing    token.Token
+	Redirects  []CommandComponent
+}
+
+func (s *SubshellCommand) Node()    {}
+func (s *SubshellCommand) Command() {}
+
+// Word node represents a command argument made up of adjacent parts, like
+// $HOME/bin or "$USER", whose values are concatenated. Arguments with a
+// single part are represented by the part itself.
+type Word struct {
+	Parts []CommandComponent
+}
+
+func (w *Word) Node()             {}
+func (w *Word) CommandComponent() {}
+
+// EnvExpansion node represents the expansion of an environment variable
+// inside a command argument, written as $NAME or ${NAME}. An unset
+// variable expands to an empty string, unless the variable is Required,
+// written as ${NAME?}, in which case it is an error.
+type EnvExpansion struct {
+	Token    token.Token
+	Name     string
+	Required bool
+}
+
+func (e *EnvExpansion) Node()             {}
+func (e *EnvExpansion) CommandComponent() {}
+
+// CommandSubstitution node represents a command whose standard output is
+// captured, written as $(command), which evaluates to the output with it's
+// trailing newlines removed. If it's Token is $?(, it evaluates to an object
+// with the stdout, stderr, and exit status of the command instead.
+type CommandSubstitution struct {
+	Token   token.Token
+	Command Command
+	Closing token.Token
+}
+
+func (c *CommandSubstitution) Node()             {}
+func (c *CommandSubstitution) Expression()       {}
+func (c *CommandSubstitution) CommandComponent() {}
+
+// TildeExpansion node represents a ~ or ~user at the start of an unquoted
+// command argument, which expands to the home directory of the current
+// user, or of the named User.
+type TildeExpansion struct {
+	Token token.Token
+	User  string
+}
+
+func (t *TildeExpansion) Node()             {}
+func (t *TildeExpansion) CommandComponent() {}
+
+// BraceExpansion node represents an unquoted brace expression of the form
+// {a,b,c} inside a command argument, which expands the argument once for
+// each of the Elements. An empty element is an empty string literal.
+type BraceExpansion struct {
+	Lbrace   token.Token
+	Elements []CommandComponent
+	Rbrace   token.Token
+}
+
+func (b *BraceExpansion) Node()             {}
+func (b *BraceExpansion) CommandComponent() {}
+
+// BraceRange node represents an unquoted brace expression of the form
+// {x..y} or {x..y..incr} inside a command argument, which expands the
+// argument once for each value from From to To, stepping by Incr. If Chars
+// is true, the bounds are characters instead of integers. Width is the
+// width to which the integers are padded with zeros, if a bound has any
+// leading zeros.
+type BraceRange struct {
+	Token    token.Token
+	From, To int
+	Incr     int
+	Chars    bool
+	Width    int
+}
+
+func (b *BraceRange) Node()             {}
+func (b *BraceRange) CommandComponent() {}
+
+// Redirect node represents a redirection of a file descriptor of a primary
+// command to or from the target.
+type Redirect struct {
+	Operator token.Token
+	Fd       int
+	Target   CommandComponent
+}
+
+func (r *Redirect) Node()             {}
+func (r *Redirect) CommandComponent() {}
+
+// Heredoc node represents a heredoc, which provides a multi-line string
+// as an input of a primary command. The body of a heredoc is split into
+// string components and embedded expressions, like a template literal.
+type Heredoc struct {
+	Operator    token.Token
+	Fd          int
+	Delimiter   string
+	Indent      bool // leading tabs are stripped from the body
+	Interpolate bool // embedded expressions are evaluated
+	Components  []token.Token
+	Expressions []Expression
+	Terminator  token.Token
+}
+
+func (h *Heredoc) Node()             {}
+func (h *Heredoc) CommandComponent() {}
+
+// Pos and End implementations for command nodes.
+
+func (l *LogicalCommand) Pos() token.Pos { return l.Left.Pos() }
+func (l *LogicalCommand) End() token.Pos { return l.Right.End() }
+func (u *UnaryCommand) Pos() token.Pos   { return u.Operator.Pos }
+func (u *UnaryCommand) End() token.Pos   { return u.Right.End() }
+func (b *BinaryCommand) Pos() token.Pos  { return b.Left.Pos() }
+func (b *BinaryCommand) End() token.Pos  { return b.Right.End() }
+
+func (l *LiteralCommand) Pos() token.Pos { return l.Components[0].Pos() }
+func (l *LiteralCommand) End() token.Pos {
+	return l.Components[len(l.Components)-1].End()
+}
+
+func (s *SubshellCommand) Pos() token.Pos { return s.Opening.Pos }
+func (s *SubshellCommand) End() token.Pos {
+	if n := len(s.Redirects); n > 0 {
+		return s.Redirects[n-1].End()
+	}
+
+	return s.Closing.End()
+}
+
+func (w *Word) Pos() token.Pos         { return w.Parts[0].Pos() }
+func (w *Word) End() token.Pos         { return w.Parts[len(w.Parts)-1].End() }
+func (e *EnvExpansion) Pos() token.Pos { return e.Token.Pos }
+func (e *EnvExpansion) End() token.Pos { return e.Token.End() }
+
+func (c *CommandSubstitution) Pos() token.Pos { return c.Token.Pos }
+func (c *CommandSubstitution) End() token.Pos { return c.Closing.End() }
+
+func (t *TildeExpansion) Pos() token.Pos { return t.Token.Pos }
+func (t *TildeExpansion) End() token.Pos { return t.Token.End() }
+func (b *BraceExpansion) Pos() token.Pos { return b.Lbrace.Pos }
+func (b *BraceExpansion) End() token.Pos { return b.Rbrace.End() }
+func (b *BraceRange) Pos() token.Pos     { return b.Token.Pos }
+func (b *BraceRange) End() token.Pos     { return b.Token.End() }
+
+func (r *Redirect) Pos() token.Pos { return r.Operator.Pos }
+func (r *Redirect) End() token.Pos { return r.Target.End() }
+
+// The span of a heredoc only covers it's operator, since it's body is
+// present after the end of the line, outside of the enclosing command.
+func (h *Heredoc) Pos() token.Pos { return h.Operator.Pos }
+func (h *Heredoc) End() token.Pos { return h.Operator.End() }
@@ -0,0 +1,141 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ast
+
+import (
+	"sort"
+
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+// Comment node represents a single # comment, which extends till the end
+// of the line.
+type Comment struct {
+	Token token.Token
+}
+
+func (c *Comment) Node() {}
+
+func (c *Comment) Pos() token.Pos { return c.Token.Pos }
+func (c *Comment) End() token.Pos { return c.Token.End() }
+
+// CommentGroup node represents a sequence of comments on consecutive lines,
+// with no other tokens between them.
+type CommentGroup struct {
+	List []*Comment
+}
+
+func (g *CommentGroup) Node() {}
+
+func (g *CommentGroup) Pos() token.Pos { return g.List[0].Pos() }
+func (g *CommentGroup) End() token.Pos { return g.List[len(g.List)-1].End() }
+
+// NodeComments holds the comments associated with a node.
+type NodeComments struct {
+	Leading  []*CommentGroup // comments before the node
+	Trailing []*CommentGroup // comments after the node
+}
+
+// CommentMap maps the nodes of a syntax tree to their associated comments.
+type CommentMap map[Node]*NodeComments
+
+// NewCommentMap creates a new comment map by associating the comment
+// groups of the list comments with the nodes of the syntax tree node. The
+// positions of the nodes and comments are resolved using the file set fset.
+//
+// A comment group is associated with a node as a trailing comment if it
+// starts on the line on which the node ends, after the node. Otherwise,
+// it is associated as a leading comment with the node which comes after
+// it. The outermost node is chosen if there are multiple such nodes.
+// Comments which are not before or after any node inside their innermost
+// enclosing node, like comments in empty blocks, are associated with the
+// enclosing node as trailing comments.
+func NewCommentMap(fset *token.FileSet, node Node, comments []*CommentGroup) CommentMap {
+	var nodes []Node
+	Inspect(node, func(n Node) bool {
+		if n != nil {
+			nodes = append(nodes, n)
+		}
+		return true
+	})
+
+	// sort nodes in source order, with outer nodes first
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if a.Pos() != b.Pos() {
+			return a.Pos() < b.Pos()
+		}
+
+		return b.End() < a.End()
+	})
+
+	cmap := make(CommentMap)
+	for _, g := range comments {
+		enclosing := node
+		for _, n := range nodes {
+			if n.Pos() < g.Pos() && n.End() >= g.End() {
+				enclosing = n
+			}
+		}
+
+		line := fset.Position(g.Pos()).Line
+
+		var trailing, leading Node
+		for _, n := range nodes {
+			if n == enclosing || !contains(enclosing, n) {
+				continue
+			}
+
+			end := n.End()
+			if fset.Position(end).Line == line && g.Pos() >= end {
+				if trailing == nil || trailing.End() < end {
+					trailing = n
+				}
+			}
+
+			if leading == nil && n.Pos() >= g.End() {
+				leading = n
+			}
+		}
+
+		switch {
+		case trailing != nil:
+			cmap.add(trailing).Trailing = append(cmap[trailing].Trailing, g)
+		case leading != nil:
+			cmap.add(leading).Leading = append(cmap[leading].Leading, g)
+		default:
+			cmap.add(enclosing).Trailing = append(cmap[enclosing].Trailing, g)
+		}
+	}
+
+	return cmap
+}
+
+// add returns the comments of node n, adding an entry for it to the map
+// if it is not present.
+func (cmap CommentMap) add(n Node) *NodeComments {
+	c, ok := cmap[n]
+	if !ok {
+		c = &NodeComments{}
+		cmap[n] = c
+	}
+
+	return c
+}
+
+// contains reports whether the span of node n is inside the span of the
+// node parent.
+func contains(parent, n Node) bool {
+	return n.Pos() >= parent.Pos() && n.End() <= parent.End()
+}
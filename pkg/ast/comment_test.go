@@ -0,0 +1,63 @@
+package ast_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/parser"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+func TestCommentMap(t *testing.T) {
+	src := `# head
+let x = 1 # x
+if x {
+	# inner
+	let y = 2
+	# end
+} # after
+func f() {
+	# empty
+}
+# tail
+`
+
+	fset := token.NewFileSet()
+	program, err := parser.Parse(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := func(groups []*ast.CommentGroup) string {
+		var list []string
+		for _, g := range groups {
+			for _, c := range g.List {
+				list = append(list, c.Token.Literal)
+			}
+		}
+		return strings.Join(list, ", ")
+	}
+
+	var got []string
+	for node, c := range program.CommentMap {
+		pos := fset.Position(node.Pos())
+		got = append(got, fmt.Sprintf("%s %T: leading [%s] trailing [%s]", &pos, node, text(c.Leading), text(c.Trailing)))
+	}
+	sort.Strings(got)
+
+	want := []string{
+		"2:1 *ast.LetStatement: leading [# head] trailing [# x]",
+		"2:1 *ast.Program: leading [] trailing [# tail]",
+		"3:1 *ast.IfStatement: leading [] trailing [# after]",
+		"3:6 *ast.BlockStatement: leading [] trailing [# end]",
+		"5:2 *ast.LetStatement: leading [# inner] trailing []",
+		"8:10 *ast.BlockStatement: leading [] trailing [# empty]",
+	}
+
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected comment map:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -70,7 +70,9 @@ func (u *UnaryExpression) Expression() {}
 
 // GroupExpression node represents a grouped expression.
 type GroupExpression struct {
-	Right Expression
+	Opening token.Token
+	Right   Expression
+	Closing token.Token
 }
 
 func (g *GroupExpression) Node()       {}
@@ -81,6 +83,7 @@ type CallExpression struct {
 	Callee      Expression
 	Parenthesis token.Token
 	Arguments   []Expression
+	Closing     token.Token
 }
 
 func (c *CallExpression) Node()       {}
@@ -88,8 +91,9 @@ func (c *CallExpression) Expression() {}
 
 // GetExpression node represents a square bracket index operation.
 type GetExpression struct {
-	Name Expression
-	Expr Expression
+	Name    Expression
+	Expr    Expression
+	Closing token.Token
 }
 
 func (g *GetExpression) Node()       {}
@@ -114,3 +118,24 @@ type VariableExpression struct {
 func (v *VariableExpression) Node()       {}
 func (v *VariableExpression) Expression() {}
 func (v *VariableExpression) Assignable() {}
+
+// Pos and End implementations for expression nodes.
+
+func (a *AssignExpression) Pos() token.Pos   { return a.Left.Pos() }
+func (a *AssignExpression) End() token.Pos   { return a.Right.End() }
+func (l *LogicalExpression) Pos() token.Pos  { return l.Left.Pos() }
+func (l *LogicalExpression) End() token.Pos  { return l.Right.End() }
+func (b *BinaryExpression) Pos() token.Pos   { return b.Left.Pos() }
+func (b *BinaryExpression) End() token.Pos   { return b.Right.End() }
+func (u *UnaryExpression) Pos() token.Pos    { return u.Operator.Pos }
+func (u *UnaryExpression) End() token.Pos    { return u.Right.End() }
+func (g *GroupExpression) Pos() token.Pos    { return g.Opening.Pos }
+func (g *GroupExpression) End() token.Pos    { return g.Closing.End() }
+func (c *CallExpression) Pos() token.Pos     { return c.Callee.Pos() }
+func (c *CallExpression) End() token.Pos     { return c.Closing.End() }
+func (g *GetExpression) Pos() token.Pos      { return g.Expr.Pos() }
+func (g *GetExpression) End() token.Pos      { return g.Closing.End() }
+func (s *SelectorExpression) Pos() token.Pos { return s.Name.Pos() }
+func (s *SelectorExpression) End() token.Pos { return s.Index.End() }
+func (v *VariableExpression) Pos() token.Pos { return v.Name.Pos }
+func (v *VariableExpression) End() token.Pos { return v.Name.End() }
@@ -15,29 +15,55 @@ package ast
 
 import "laptudirm.com/x/mash/pkg/token"
 
-// NumberLiteral node represents a number constant.
-type NumberLiteral struct {
+// IntLiteral node represents an integer constant.
+type IntLiteral struct {
+	Token token.Token
+	Value int64
+}
+
+func (n *IntLiteral) Node()       {}
+func (n *IntLiteral) Expression() {}
+
+// FloatLiteral node represents a floating point constant.
+type FloatLiteral struct {
 	Token token.Token
 	Value float64
 }
 
-func (n *NumberLiteral) Node()       {}
-func (n *NumberLiteral) Expression() {}
+func (n *FloatLiteral) Node()       {}
+func (n *FloatLiteral) Expression() {}
 
-// StringLiteral node represents a string constant.
+// StringLiteral node represents a string constant. Quoted reports whether
+// the string was quoted in the source, since the unquoted words of a command
+// are expanded as glob patterns.
 type StringLiteral struct {
-	Token token.Token
-	Value string
+	Token  token.Token
+	Value  string
+	Quoted bool
 }
 
 func (n *StringLiteral) Node()             {}
 func (n *StringLiteral) Expression()       {}
 func (n *StringLiteral) CommandComponent() {}
 
-// FunctionLiteral node represents a function expression.
-type FunctionLiteral struct {
+// BooleanLiteral node represents a boolean constant. It isn't produced by
+// the parser, since true and false are variables, but by constant folding
+// of comparisons and logical expressions.
+type BooleanLiteral struct {
 	Token token.Token
-	Block *BlockStatement
+	Value bool
+}
+
+func (n *BooleanLiteral) Node()       {}
+func (n *BooleanLiteral) Expression() {}
+
+// FunctionLiteral node represents a function expression. If Variadic is
+// true, the last parameter collects the remaining arguments of a call.
+type FunctionLiteral struct {
+	Token      token.Token
+	Parameters []token.Token
+	Variadic   bool
+	Block      *BlockStatement
 }
 
 func (n *FunctionLiteral) Node()       {}
@@ -47,6 +73,7 @@ func (n *FunctionLiteral) Expression() {}
 type ArrayLiteral struct {
 	Token    token.Token
 	Elements []Expression
+	Closing  token.Token
 }
 
 func (a *ArrayLiteral) Node()       {}
@@ -56,6 +83,7 @@ func (a *ArrayLiteral) Expression() {}
 type ObjectLiteral struct {
 	Token    token.Token
 	Elements map[Expression]Expression
+	Closing  token.Token
 }
 
 func (o *ObjectLiteral) Node()       {}
@@ -63,10 +91,31 @@ func (o *ObjectLiteral) Expression() {}
 
 // TemplateLiteral node represents a template string expression.
 type TemplateLiteral struct {
+	Token       token.Token
 	Expressions []Expression
 	Components  []token.Token
+	Closing     token.Token
 }
 
 func (t *TemplateLiteral) Node()             {}
 func (t *TemplateLiteral) Expression()       {}
 func (t *TemplateLiteral) CommandComponent() {}
+
+// Pos and End implementations for literal nodes.
+
+func (n *IntLiteral) Pos() token.Pos      { return n.Token.Pos }
+func (n *IntLiteral) End() token.Pos      { return n.Token.End() }
+func (n *FloatLiteral) Pos() token.Pos    { return n.Token.Pos }
+func (n *FloatLiteral) End() token.Pos    { return n.Token.End() }
+func (n *StringLiteral) Pos() token.Pos   { return n.Token.Pos }
+func (n *StringLiteral) End() token.Pos   { return n.Token.End() }
+func (n *BooleanLiteral) Pos() token.Pos  { return n.Token.Pos }
+func (n *BooleanLiteral) End() token.Pos  { return n.Token.End() }
+func (n *FunctionLiteral) Pos() token.Pos { return n.Token.Pos }
+func (n *FunctionLiteral) End() token.Pos { return n.Block.End() }
+func (a *ArrayLiteral) Pos() token.Pos    { return a.Token.Pos }
+func (a *ArrayLiteral) End() token.Pos    { return a.Closing.End() }
+func (o *ObjectLiteral) Pos() token.Pos   { return o.Token.Pos }
+func (o *ObjectLiteral) End() token.Pos   { return o.Closing.End() }
+func (t *TemplateLiteral) Pos() token.Pos { return t.Token.Pos }
+func (t *TemplateLiteral) End() token.Pos { return t.Closing.End() }
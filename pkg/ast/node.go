@@ -15,8 +15,12 @@
 // the abstract syntax tree of a mash program.
 package ast
 
+import "laptudirm.com/x/mash/pkg/token"
+
 // Node interface is implemented by every node of the mash abstract syntax
 // tree.
 type Node interface {
 	Node()
+	Pos() token.Pos // position of the first character of the node
+	End() token.Pos // position of the character immediately after the node
 }
@@ -13,9 +13,29 @@
 
 package ast
 
+import "laptudirm.com/x/mash/pkg/token"
+
 // Program node represents a mash program.
 type Program struct {
 	Statements []Statement
+	Comments   []*CommentGroup // list of all comments in the source
+	CommentMap CommentMap      // comments associated with the nodes
 }
 
 func (p *Program) Node() {}
+
+func (p *Program) Pos() token.Pos {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+
+	return token.NoPos
+}
+
+func (p *Program) End() token.Pos {
+	if n := len(p.Statements); n > 0 {
+		return p.Statements[n-1].End()
+	}
+
+	return token.NoPos
+}
@@ -13,6 +13,8 @@
 
 package ast
 
+import "laptudirm.com/x/mash/pkg/token"
+
 // Statement is the interface implemented by statement nodes.
 type Statement interface {
 	Node
@@ -21,7 +23,9 @@ type Statement interface {
 
 // BlockStatement represents a scoped block Statement.
 type BlockStatement struct {
+	Opening    token.Token
 	Statements []Statement
+	Closing    token.Token
 }
 
 func (b *BlockStatement) Node()      {}
@@ -29,6 +33,7 @@ func (b *BlockStatement) Statement() {}
 
 // IfStatements represents  if-else if-else conditional statement.
 type IfStatement struct {
+	Token     token.Token
 	Condition Expression
 	BlockStmt *BlockStatement
 	ElseBlock Statement
@@ -39,6 +44,7 @@ func (i *IfStatement) Statement() {}
 
 // ForStatement represents a for looping statement.
 type ForStatement struct {
+	Token     token.Token
 	Condition Expression
 	BlockStmt *BlockStatement
 }
@@ -46,18 +52,102 @@ type ForStatement struct {
 func (f *ForStatement) Node()      {}
 func (f *ForStatement) Statement() {}
 
+// FuncStatement represents a named function declaration statement.
+type FuncStatement struct {
+	Name     token.Token
+	Function *FunctionLiteral
+}
+
+func (f *FuncStatement) Node()      {}
+func (f *FuncStatement) Statement() {}
+
+// BreakStatement represents a break statement, which terminates the
+// innermost for loop.
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (b *BreakStatement) Node()      {}
+func (b *BreakStatement) Statement() {}
+
+// ContinueStatement represents a continue statement, which begins the next
+// iteration of the innermost for loop.
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (c *ContinueStatement) Node()      {}
+func (c *ContinueStatement) Statement() {}
+
+// ReturnStatement represents a return statement, with an optional result
+// expression.
+type ReturnStatement struct {
+	Token  token.Token
+	Result Expression
+}
+
+func (r *ReturnStatement) Node()      {}
+func (r *ReturnStatement) Statement() {}
+
 // LetStatement represents a let expression statement.
 type LetStatement struct {
+	Token      token.Token
 	Expression Expression
 }
 
 func (l *LetStatement) Node()      {}
 func (l *LetStatement) Statement() {}
 
-// CmdStatement represents a shell command statement.
+// CmdStatement represents a shell command statement. If the statement ends
+// with a Background token &, the command is run in the background.
 type CmdStatement struct {
-	Command Command
+	Command    Command
+	Background token.Token
 }
 
 func (c *CmdStatement) Node()      {}
 func (c *CmdStatement) Statement() {}
+
+// Pos and End implementations for statement nodes.
+
+func (b *BlockStatement) Pos() token.Pos { return b.Opening.Pos }
+func (b *BlockStatement) End() token.Pos { return b.Closing.End() }
+
+func (i *IfStatement) Pos() token.Pos { return i.Token.Pos }
+func (i *IfStatement) End() token.Pos {
+	if i.ElseBlock != nil {
+		return i.ElseBlock.End()
+	}
+
+	return i.BlockStmt.End()
+}
+
+func (f *ForStatement) Pos() token.Pos  { return f.Token.Pos }
+func (f *ForStatement) End() token.Pos  { return f.BlockStmt.End() }
+func (f *FuncStatement) Pos() token.Pos { return f.Function.Pos() }
+func (f *FuncStatement) End() token.Pos { return f.Function.End() }
+
+func (b *BreakStatement) Pos() token.Pos    { return b.Token.Pos }
+func (b *BreakStatement) End() token.Pos    { return b.Token.End() }
+func (c *ContinueStatement) Pos() token.Pos { return c.Token.Pos }
+func (c *ContinueStatement) End() token.Pos { return c.Token.End() }
+
+func (r *ReturnStatement) Pos() token.Pos { return r.Token.Pos }
+func (r *ReturnStatement) End() token.Pos {
+	if r.Result != nil {
+		return r.Result.End()
+	}
+
+	return r.Token.End()
+}
+
+func (l *LetStatement) Pos() token.Pos { return l.Token.Pos }
+func (l *LetStatement) End() token.Pos { return l.Expression.End() }
+func (c *CmdStatement) Pos() token.Pos { return c.Command.Pos() }
+func (c *CmdStatement) End() token.Pos {
+	if c.Background.Type == token.And {
+		return c.Background.End()
+	}
+
+	return c.Command.End()
+}
@@ -0,0 +1,185 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order: It starts by calling
+// v.Visit(node); node must not be nil. If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor
+// w for each of the non-nil children of node, followed by a call of
+// w.Visit(nil). The entries of an ObjectLiteral are visited in an
+// unspecified order, with each key followed by it's value. The comments
+// of a Program are not visited.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+
+	// Comments
+	case *Comment:
+		// nothing to do
+	case *CommentGroup:
+		for _, c := range n.List {
+			Walk(v, c)
+		}
+
+	// Statements
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *IfStatement:
+		Walk(v, n.Condition)
+		Walk(v, n.BlockStmt)
+		if n.ElseBlock != nil {
+			Walk(v, n.ElseBlock)
+		}
+	case *ForStatement:
+		if n.Condition != nil {
+			Walk(v, n.Condition)
+		}
+		Walk(v, n.BlockStmt)
+	case *FuncStatement:
+		Walk(v, n.Function)
+	case *BreakStatement, *ContinueStatement:
+		// nothing to do
+	case *ReturnStatement:
+		if n.Result != nil {
+			Walk(v, n.Result)
+		}
+	case *LetStatement:
+		Walk(v, n.Expression)
+	case *CmdStatement:
+		Walk(v, n.Command)
+
+	// Expressions
+	case *AssignExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+	case *LogicalExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+	case *BinaryExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+	case *UnaryExpression:
+		Walk(v, n.Right)
+	case *GroupExpression:
+		Walk(v, n.Right)
+	case *CallExpression:
+		Walk(v, n.Callee)
+		walkExpressions(v, n.Arguments)
+	case *GetExpression:
+		Walk(v, n.Expr)
+		Walk(v, n.Name)
+	case *SelectorExpression:
+		Walk(v, n.Name)
+	case *VariableExpression:
+		// nothing to do
+
+	// Literals
+	case *IntLiteral, *FloatLiteral, *StringLiteral, *BooleanLiteral:
+		// nothing to do
+	case *FunctionLiteral:
+		Walk(v, n.Block)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *ObjectLiteral:
+		for key, value := range n.Elements {
+			Walk(v, key)
+			Walk(v, value)
+		}
+	case *TemplateLiteral:
+		walkExpressions(v, n.Expressions)
+
+	// Commands
+	case *LogicalCommand:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+	case *BinaryCommand:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+	case *UnaryCommand:
+		Walk(v, n.Right)
+	case *SubshellCommand:
+		walkStatements(v, n.Statements)
+		for _, r := range n.Redirects {
+			Walk(v, r)
+		}
+	case *LiteralCommand:
+		for _, c := range n.Components {
+			Walk(v, c)
+		}
+	case *Word:
+		for _, part := range n.Parts {
+			Walk(v, part)
+		}
+	case *CommandSubstitution:
+		Walk(v, n.Command)
+	case *EnvExpansion, *TildeExpansion, *BraceRange:
+		// nothing to do
+	case *BraceExpansion:
+		for _, elem := range n.Elements {
+			Walk(v, elem)
+		}
+	case *Redirect:
+		Walk(v, n.Target)
+	case *Heredoc:
+		walkExpressions(v, n.Expressions)
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(v Visitor, list []Statement) {
+	for _, stmt := range list {
+		Walk(v, stmt)
+	}
+}
+
+func walkExpressions(v Visitor, list []Expression) {
+	for _, expr := range list {
+		Walk(v, expr)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: It starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,345 @@
+package ast_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+func num(v int64) *ast.IntLiteral {
+	return &ast.IntLiteral{Value: v}
+}
+
+func variable(name string) *ast.VariableExpression {
+	return &ast.VariableExpression{Name: token.Token{Type: token.Identifier, Literal: name}}
+}
+
+// program returns a program which contains every type of node.
+func program() *ast.Program {
+	return &ast.Program{
+		Statements: []ast.Statement{
+			&ast.LetStatement{
+				Expression: &ast.AssignExpression{
+					Left: variable("x"),
+					Right: &ast.LogicalExpression{
+						Left: &ast.BinaryExpression{
+							Left:  &ast.UnaryExpression{Right: num(1)},
+							Right: &ast.GroupExpression{Right: &ast.FloatLiteral{Value: 2.5}},
+						},
+						Right: &ast.CallExpression{
+							Callee: &ast.SelectorExpression{Name: variable("o")},
+							Arguments: []ast.Expression{
+								&ast.GetExpression{Expr: variable("a"), Name: num(0)},
+							},
+						},
+					},
+				},
+			},
+			&ast.IfStatement{
+				Condition: &ast.BooleanLiteral{Value: true},
+				BlockStmt: &ast.BlockStatement{},
+				ElseBlock: &ast.BlockStatement{
+					Statements: []ast.Statement{
+						&ast.LetStatement{
+							Expression: &ast.ArrayLiteral{
+								Elements: []ast.Expression{
+									&ast.ObjectLiteral{
+										Elements: map[ast.Expression]ast.Expression{
+											&ast.StringLiteral{Value: "k"}: num(3),
+										},
+									},
+									&ast.TemplateLiteral{
+										Expressions: []ast.Expression{variable("t")},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			&ast.ForStatement{
+				Condition: variable("f"),
+				BlockStmt: &ast.BlockStatement{
+					Statements: []ast.Statement{
+						&ast.BreakStatement{},
+						&ast.ContinueStatement{},
+					},
+				},
+			},
+			&ast.FuncStatement{
+				Function: &ast.FunctionLiteral{
+					Block: &ast.BlockStatement{
+						Statements: []ast.Statement{
+							&ast.ReturnStatement{Result: num(4)},
+						},
+					},
+				},
+			},
+			&ast.CmdStatement{
+				Command: &ast.LogicalCommand{
+					Left: &ast.UnaryCommand{
+						Right: &ast.LiteralCommand{
+							Components: []ast.CommandComponent{
+								&ast.StringLiteral{Value: "echo"},
+								&ast.Redirect{Target: &ast.StringLiteral{Value: "file"}},
+							},
+						},
+					},
+					Right: &ast.BinaryCommand{
+						Left: &ast.LiteralCommand{
+							Components: []ast.CommandComponent{
+								&ast.StringLiteral{Value: "cat"},
+								&ast.Heredoc{Expressions: []ast.Expression{variable("h")}},
+							},
+						},
+						Right: &ast.LiteralCommand{
+							Components: []ast.CommandComponent{
+								&ast.TemplateLiteral{},
+							},
+						},
+					},
+				},
+			},
+			&ast.CmdStatement{
+				Command: &ast.LiteralCommand{
+					Components: []ast.CommandComponent{
+						&ast.Word{
+							Parts: []ast.CommandComponent{
+								&ast.StringLiteral{Value: "a"},
+								&ast.EnvExpansion{Name: "HOME"},
+							},
+						},
+					},
+				},
+			},
+			&ast.CmdStatement{
+				Command: &ast.LiteralCommand{
+					Components: []ast.CommandComponent{
+						&ast.TildeExpansion{},
+						&ast.BraceExpansion{
+							Elements: []ast.CommandComponent{
+								&ast.StringLiteral{Value: "b"},
+								&ast.BraceRange{From: 1, To: 3},
+							},
+						},
+					},
+				},
+			},
+			&ast.CmdStatement{
+				Command: &ast.LiteralCommand{
+					Components: []ast.CommandComponent{
+						&ast.CommandSubstitution{
+							Command: &ast.LiteralCommand{
+								Components: []ast.CommandComponent{
+									&ast.StringLiteral{Value: "pwd"},
+								},
+							},
+						},
+					},
+				},
+			},
+			&ast.CmdStatement{
+				Command: &ast.SubshellCommand{
+					Statements: []ast.Statement{
+						&ast.CmdStatement{
+							Command: &ast.LiteralCommand{
+								Components: []ast.CommandComponent{
+									&ast.StringLiteral{Value: "cd"},
+								},
+							},
+						},
+					},
+					Redirects: []ast.CommandComponent{
+						&ast.Redirect{Target: &ast.StringLiteral{Value: "file"}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestInspect(t *testing.T) {
+	var got []string
+	ast.Inspect(program(), func(n ast.Node) bool {
+		if n != nil {
+			got = append(got, fmt.Sprintf("%T", n))
+		}
+		return true
+	})
+
+	want := []string{
+		"*ast.Program",
+		"*ast.LetStatement",
+		"*ast.AssignExpression",
+		"*ast.VariableExpression",
+		"*ast.LogicalExpression",
+		"*ast.BinaryExpression",
+		"*ast.UnaryExpression",
+		"*ast.IntLiteral",
+		"*ast.GroupExpression",
+		"*ast.FloatLiteral",
+		"*ast.CallExpression",
+		"*ast.SelectorExpression",
+		"*ast.VariableExpression",
+		"*ast.GetExpression",
+		"*ast.VariableExpression",
+		"*ast.IntLiteral",
+		"*ast.IfStatement",
+		"*ast.BooleanLiteral",
+		"*ast.BlockStatement",
+		"*ast.BlockStatement",
+		"*ast.LetStatement",
+		"*ast.ArrayLiteral",
+		"*ast.ObjectLiteral",
+		"*ast.StringLiteral",
+		"*ast.IntLiteral",
+		"*ast.TemplateLiteral",
+		"*ast.VariableExpression",
+		"*ast.ForStatement",
+		"*ast.VariableExpression",
+		"*ast.BlockStatement",
+		"*ast.BreakStatement",
+		"*ast.ContinueStatement",
+		"*ast.FuncStatement",
+		"*ast.FunctionLiteral",
+		"*ast.BlockStatement",
+		"*ast.ReturnStatement",
+		"*ast.IntLiteral",
+		"*ast.CmdStatement",
+		"*ast.LogicalCommand",
+		"*ast.UnaryCommand",
+		"*ast.LiteralCommand",
+		"*ast.StringLiteral",
+		"*ast.Redirect",
+		"*ast.StringLiteral",
+		"*ast.BinaryCommand",
+		"*ast.LiteralCommand",
+		"*ast.StringLiteral",
+		"*ast.Heredoc",
+		"*ast.VariableExpression",
+		"*ast.LiteralCommand",
+		"*ast.TemplateLiteral",
+		"*ast.CmdStatement",
+		"*ast.LiteralCommand",
+		"*ast.Word",
+		"*ast.StringLiteral",
+		"*ast.EnvExpansion",
+		"*ast.CmdStatement",
+		"*ast.LiteralCommand",
+		"*ast.TildeExpansion",
+		"*ast.BraceExpansion",
+		"*ast.StringLiteral",
+		"*ast.BraceRange",
+		"*ast.CmdStatement",
+		"*ast.LiteralCommand",
+		"*ast.CommandSubstitution",
+		"*ast.LiteralCommand",
+		"*ast.StringLiteral",
+		"*ast.CmdStatement",
+		"*ast.SubshellCommand",
+		"*ast.CmdStatement",
+		"*ast.LiteralCommand",
+		"*ast.StringLiteral",
+		"*ast.Redirect",
+		"*ast.StringLiteral",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected traversal:\nreceived %v\nexpected %v", got, want)
+	}
+}
+
+func TestInspectPrune(t *testing.T) {
+	p := program()
+
+	var count int
+	ast.Inspect(p, func(n ast.Node) bool {
+		if n != nil {
+			count++
+		}
+
+		// don't descend into statements
+		_, ok := n.(*ast.Program)
+		return ok
+	})
+
+	if want := len(p.Statements) + 1; count != want {
+		t.Errorf("expected %d nodes, received %d", want, count)
+	}
+}
+
+// shape is a visitor which records the shape of the walked tree, with the
+// children of every node inside parentheses after it's type. The closing
+// parenthesis is written by the call of Visit(nil) after the children.
+type shape struct {
+	b *strings.Builder
+}
+
+func (s shape) Visit(n ast.Node) ast.Visitor {
+	if n == nil {
+		s.b.WriteString(")")
+		return nil
+	}
+
+	if str := s.b.String(); str != "" && !strings.HasSuffix(str, "(") {
+		s.b.WriteString(" ")
+	}
+
+	s.b.WriteString(strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.") + "(")
+	return s
+}
+
+func TestWalk(t *testing.T) {
+	tests := []struct {
+		node ast.Node
+		want string
+	}{
+		{
+			&ast.LetStatement{
+				Expression: &ast.AssignExpression{
+					Left: variable("x"),
+					Right: &ast.BinaryExpression{
+						Left:  num(1),
+						Right: &ast.GroupExpression{Right: &ast.FloatLiteral{Value: 2.5}},
+					},
+				},
+			},
+			"LetStatement(AssignExpression(VariableExpression() BinaryExpression(IntLiteral() GroupExpression(FloatLiteral()))))",
+		},
+		{
+			&ast.CmdStatement{
+				Command: &ast.LiteralCommand{
+					Components: []ast.CommandComponent{
+						&ast.StringLiteral{Value: "cat"},
+						&ast.Heredoc{Expressions: []ast.Expression{variable("h")}},
+					},
+				},
+			},
+			"CmdStatement(LiteralCommand(StringLiteral() Heredoc(VariableExpression())))",
+		},
+		{
+			&ast.CommentGroup{List: []*ast.Comment{{}, {}}},
+			"CommentGroup(Comment() Comment())",
+		},
+	}
+
+	for _, test := range tests {
+		var b strings.Builder
+		ast.Walk(shape{&b}, test.node)
+
+		if got := b.String(); got != test.want {
+			t.Errorf("unexpected shape:\nreceived %s\nexpected %s", got, test.want)
+		}
+	}
+
+	// every node of program is visited and followed by a call of Visit(nil)
+	var b strings.Builder
+	ast.Walk(shape{&b}, program())
+	if open, closed := strings.Count(b.String(), "("), strings.Count(b.String(), ")"); open != closed {
+		t.Errorf("expected a call of Visit(nil) for every node, received %d nodes and %d calls", open, closed)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diagnostics implements structured diagnostics, which are used
+// to report errors in mash programs.
+package diagnostics
+
+import (
+	"fmt"
+	"sort"
+
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+// Severity represents the severity of a diagnostic.
+type Severity int
+
+// Various severities of diagnostics.
+const (
+	SeverityError   Severity = iota // program can't be executed
+	SeverityWarning                 // program is likely to be incorrect
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// Codes of the diagnostics reported by the lexer, the parser, and constant
+// folding.
+const (
+	CodeLexical  = "lexical"  // invalid token
+	CodeSyntax   = "syntax"   // unexpected token
+	CodeBreak    = "break"    // break statement outside a loop
+	CodeContinue = "continue" // continue statement outside a loop
+	CodeReturn   = "return"   // return statement outside a function
+	CodeConstant = "constant" // invalid constant expression
+)
+
+// Fix represents a suggested fix of a diagnostic, which replaces the text
+// of the source between the positions Pos and End with NewText.
+type Fix struct {
+	Message  string // description of the fix
+	Pos, End token.Position
+	NewText  string
+}
+
+// Error represents a diagnostic at a position in the source.
+type Error struct {
+	Position token.Position
+	Severity Severity
+	Code     string // category of the diagnostic
+	Message  string
+	Fix      *Fix // optional suggested fix
+}
+
+// Error implements the error interface. Diagnostics other than errors are
+// prefixed with their severity.
+func (e *Error) Error() string {
+	if e.Severity != SeverityError {
+		return fmt.Sprintf("%s: %s: %s", &e.Position, e.Severity, e.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", &e.Position, e.Message)
+}
+
+// ErrorList is a list of diagnostics, which implements the error
+// interface.
+type ErrorList []*Error
+
+// Add adds an error diagnostic with the message of err and the code code
+// at the position pos to the list.
+func (l *ErrorList) Add(pos token.Position, code string, err error) {
+	*l = append(*l, &Error{
+		Position: pos,
+		Severity: SeverityError,
+		Code:     code,
+		Message:  err.Error(),
+	})
+}
+
+// ErrorList implements the sort.Interface.
+
+func (l ErrorList) Len() int      { return len(l) }
+func (l ErrorList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+func (l ErrorList) Less(i, j int) bool {
+	a, b := l[i], l[j]
+	switch {
+	case a.Position.Filename != b.Position.Filename:
+		return a.Position.Filename < b.Position.Filename
+	case a.Position.Line != b.Position.Line:
+		return a.Position.Line < b.Position.Line
+	case a.Position.Col != b.Position.Col:
+		return a.Position.Col < b.Position.Col
+	case a.Severity != b.Severity:
+		return a.Severity < b.Severity
+	default:
+		return a.Message < b.Message
+	}
+}
+
+// Sort sorts the list by file name, position, severity, and message.
+func (l ErrorList) Sort() {
+	sort.Stable(l)
+}
+
+// RemoveMultiples sorts the list and removes all but the first diagnostic
+// on each line of a file, since later diagnostics on a line are usually
+// caused by the first one.
+func (l *ErrorList) RemoveMultiples() {
+	l.Sort()
+
+	var last token.Position // position of the last diagnostic kept
+	i := 0
+	for _, e := range *l {
+		if e.Position.Filename != last.Filename || e.Position.Line != last.Line || i == 0 {
+			last = e.Position
+			(*l)[i] = e
+			i++
+		}
+	}
+
+	*l = (*l)[:i]
+}
+
+// Error implements the error interface.
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	default:
+		return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+	}
+}
+
+// Err returns an error equivalent to the list, or nil if it is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+
+	return l
+}
@@ -0,0 +1,50 @@
+package diagnostics
+
+import (
+	"errors"
+	"testing"
+
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+func pos(line, col int) token.Position {
+	return token.Position{Line: line, Col: col}
+}
+
+func TestErrorList(t *testing.T) {
+	var list ErrorList
+	if list.Err() != nil {
+		t.Errorf("expected nil error from empty list, received %v", list.Err())
+	}
+
+	list.Add(pos(3, 1), CodeSyntax, errors.New("c"))
+	list.Add(pos(1, 5), CodeSyntax, errors.New("b"))
+	list = append(list, &Error{Position: pos(1, 5), Severity: SeverityWarning, Message: "a"})
+	list.Add(pos(1, 2), CodeLexical, errors.New("a"))
+	list.Add(pos(3, 1), CodeSyntax, errors.New("a"))
+
+	list.Sort()
+
+	want := []string{
+		"1:2: a",
+		"1:5: b",
+		"1:5: warning: a",
+		"3:1: a",
+		"3:1: c",
+	}
+
+	for i, e := range list {
+		if e.Error() != want[i] {
+			t.Errorf("sorted error %d: expected %q, received %q", i, want[i], e)
+		}
+	}
+
+	list.RemoveMultiples()
+	if len(list) != 2 || list[0].Error() != "1:2: a" || list[1].Error() != "3:1: a" {
+		t.Errorf("expected first error on each line, received %v", []*Error(list))
+	}
+
+	if got := list.Err().Error(); got != "1:2: a (and 1 more errors)" {
+		t.Errorf("unexpected list error %q", got)
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fold
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+// The operators of the language are implemented on constants, which are
+// int64, float64, string, and bool values, or nil. They are used by the
+// interpreter too, so that folding a constant expression never changes the
+// result of a program. Other values only support the equality and logical
+// operators, and are compared by identity.
+
+// ErrDivByZero is returned when a number is divided by zero.
+var ErrDivByZero = errors.New("division by zero")
+
+// ErrOverflow is returned when the result of an integer operation can't be
+// represented by an int64.
+var ErrOverflow = errors.New("integer overflow")
+
+// typeOf returns the name of the type of the value v. Values other than
+// constants provide the name of their type with a Type method.
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case int64:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case interface{ Type() string }:
+		return v.Type()
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// Truthy reports whether v is considered true in a conditional context.
+// The values nil, false, 0, and "" are false, while every other value is
+// true.
+func Truthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	default:
+		return true
+	}
+}
+
+// BinaryOp applies the binary operator op to the operands left and right.
+// Operations on an integer and a float convert the integer into a float.
+func BinaryOp(op token.Type, left, right any) (any, error) {
+	switch op {
+	case token.Equal:
+		return equal(left, right), nil
+	case token.NotEqual:
+		return !equal(left, right), nil
+	}
+
+	switch l := left.(type) {
+	case int64:
+		switch r := right.(type) {
+		case int64:
+			return intOp(op, l, r)
+		case float64:
+			return floatOp(op, float64(l), r)
+		}
+	case float64:
+		switch r := right.(type) {
+		case int64:
+			return floatOp(op, l, float64(r))
+		case float64:
+			return floatOp(op, l, r)
+		}
+	case string:
+		if r, ok := right.(string); ok {
+			return stringOp(op, l, r)
+		}
+	}
+
+	return nil, fmt.Errorf("invalid operation: %s %s %s", typeOf(left), op, typeOf(right))
+}
+
+// equal reports whether the values l and r are equal. Integers and floats
+// are equal if they represent the same number.
+func equal(l, r any) bool {
+	switch l := l.(type) {
+	case int64:
+		if r, ok := r.(float64); ok {
+			return float64(l) == r
+		}
+	case float64:
+		if r, ok := r.(int64); ok {
+			return l == float64(r)
+		}
+	}
+
+	return l == r
+}
+
+// intOp applies the operator op to the integers l and r. Results of
+// arithmetic operations which overflow are reported as errors, while the
+// shift operators discard the bits which are shifted out. Division
+// truncates towards zero.
+func intOp(op token.Type, l, r int64) (any, error) {
+	switch op {
+	case token.Addition:
+		sum := l + r
+		if (r > 0 && sum < l) || (r < 0 && sum > l) {
+			return nil, ErrOverflow
+		}
+
+		return sum, nil
+	case token.Subtraction:
+		diff := l - r
+		if (r > 0 && diff > l) || (r < 0 && diff < l) {
+			return nil, ErrOverflow
+		}
+
+		return diff, nil
+	case token.Multiplication:
+		if l == 0 || r == 0 {
+			return int64(0), nil
+		}
+
+		prod := l * r
+		if prod/r != l || (l == -1 && r == math.MinInt64) || (r == -1 && l == math.MinInt64) {
+			return nil, ErrOverflow
+		}
+
+		return prod, nil
+	case token.Quotient:
+		switch {
+		case r == 0:
+			return nil, ErrDivByZero
+		case l == math.MinInt64 && r == -1:
+			return nil, ErrOverflow
+		}
+
+		return l / r, nil
+	case token.Remainder:
+		if r == 0 {
+			return nil, ErrDivByZero
+		}
+
+		return l % r, nil
+
+	case token.LessThan:
+		return l < r, nil
+	case token.LessThanEqual:
+		return l <= r, nil
+	case token.GreaterThan:
+		return l > r, nil
+	case token.GreaterThanEqual:
+		return l >= r, nil
+
+	case token.And:
+		return l & r, nil
+	case token.Or:
+		return l | r, nil
+	case token.Xor:
+		return l ^ r, nil
+	case token.AndNot:
+		return l &^ r, nil
+	case token.ShiftLeft, token.ShiftRight:
+		if r < 0 {
+			return nil, fmt.Errorf("negative shift count %d", r)
+		}
+
+		if op == token.ShiftLeft {
+			return l << uint64(r), nil
+		}
+
+		return l >> uint64(r), nil
+	default:
+		return nil, fmt.Errorf("invalid operation: int %s int", op)
+	}
+}
+
+// floatOp applies the operator op to the floats l and r. Bitwise
+// operators are only defined on integers.
+func floatOp(op token.Type, l, r float64) (any, error) {
+	switch op {
+	case token.Addition:
+		return l + r, nil
+	case token.Subtraction:
+		return l - r, nil
+	case token.Multiplication:
+		return l * r, nil
+	case token.Quotient:
+		if r == 0 {
+			return nil, ErrDivByZero
+		}
+
+		return l / r, nil
+	case token.Remainder:
+		if r == 0 {
+			return nil, ErrDivByZero
+		}
+
+		return math.Mod(l, r), nil
+
+	case token.LessThan:
+		return l < r, nil
+	case token.LessThanEqual:
+		return l <= r, nil
+	case token.GreaterThan:
+		return l > r, nil
+	case token.GreaterThanEqual:
+		return l >= r, nil
+	default:
+		return nil, fmt.Errorf("invalid operation: float %s float", op)
+	}
+}
+
+func stringOp(op token.Type, l, r string) (any, error) {
+	switch op {
+	case token.Addition:
+		return l + r, nil
+	case token.LessThan:
+		return l < r, nil
+	case token.LessThanEqual:
+		return l <= r, nil
+	case token.GreaterThan:
+		return l > r, nil
+	case token.GreaterThanEqual:
+		return l >= r, nil
+	default:
+		return nil, fmt.Errorf("invalid operation: string %s string", op)
+	}
+}
+
+// UnaryOp applies the unary operator op to the operand v.
+func UnaryOp(op token.Type, v any) (any, error) {
+	if op == token.Not {
+		return !Truthy(v), nil
+	}
+
+	switch v := v.(type) {
+	case int64:
+		switch op {
+		case token.Addition:
+			return v, nil
+		case token.Subtraction:
+			if v == math.MinInt64 {
+				return nil, ErrOverflow
+			}
+
+			return -v, nil
+		case token.Xor:
+			return ^v, nil
+		}
+	case float64:
+		switch op {
+		case token.Addition:
+			return v, nil
+		case token.Subtraction:
+			return -v, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid operation: %s%s", op, typeOf(v))
+}
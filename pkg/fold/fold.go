@@ -0,0 +1,271 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fold implements constant folding, which evaluates the constant
+// expressions of a mash syntax tree before it is run.
+package fold
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/diagnostics"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+// Fold replaces the constant expressions in the AST node with literals of
+// their values, which have the position of the replaced expression. The
+// constant expressions which can't be evaluated, like divisions by zero,
+// are left as is, so that they only fail if they are run, and are returned
+// as a diagnostics.ErrorList of warnings. Constant expressions are
+// described by Constant.
+func Fold(fset *token.FileSet, node ast.Node) error {
+	f := &folder{fset: fset, values: make(map[ast.Expression]any)}
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.LetStatement:
+			n.Expression = f.fold(n.Expression)
+		case *ast.IfStatement:
+			n.Condition = f.fold(n.Condition)
+		case *ast.ForStatement:
+			if n.Condition != nil {
+				n.Condition = f.fold(n.Condition)
+			}
+		case *ast.ReturnStatement:
+			if n.Result != nil {
+				n.Result = f.fold(n.Result)
+			}
+		case *ast.AssignExpression:
+			n.Right = f.fold(n.Right)
+		case *ast.LogicalExpression:
+			n.Left = f.fold(n.Left)
+			n.Right = f.fold(n.Right)
+		case *ast.BinaryExpression:
+			n.Left = f.fold(n.Left)
+			n.Right = f.fold(n.Right)
+		case *ast.UnaryExpression:
+			n.Right = f.fold(n.Right)
+		case *ast.GroupExpression:
+			n.Right = f.fold(n.Right)
+		case *ast.CallExpression:
+			n.Callee = f.fold(n.Callee)
+			f.foldList(n.Arguments)
+		case *ast.GetExpression:
+			n.Expr = f.fold(n.Expr)
+			n.Name = f.fold(n.Name)
+		case *ast.SelectorExpression:
+			n.Name = f.fold(n.Name)
+		case *ast.ArrayLiteral:
+			f.foldList(n.Elements)
+		case *ast.ObjectLiteral:
+			elements := make(map[ast.Expression]ast.Expression, len(n.Elements))
+			for key, value := range n.Elements {
+				elements[f.fold(key)] = f.fold(value)
+			}
+			n.Elements = elements
+		case *ast.TemplateLiteral:
+			f.foldList(n.Expressions)
+		case *ast.Heredoc:
+			f.foldList(n.Expressions)
+		}
+		return true
+	})
+
+	f.errors.Sort()
+	return f.errors.Err()
+}
+
+// Constant returns the value of the constant expression expr, which is an
+// int64, float64, string, or bool, and reports whether expr is a constant
+// expression which can be evaluated. Literals other than function, array,
+// object, and template literals are constant, and so are unary, binary, and
+// group expressions of constant operands. A logical expression is constant
+// if its operands are constant, or if its result is decided by a constant
+// left operand. Variables, including true and false, are never constant.
+func Constant(expr ast.Expression) (any, bool) {
+	f := &folder{values: make(map[ast.Expression]any)}
+	v := f.eval(expr)
+	return v, v != nil
+}
+
+// folder folds the constant expressions of an AST. The values of the
+// evaluated expressions are recorded, with nil for expressions which aren't
+// constant, so that each expression is evaluated, and each error reported,
+// only once.
+type folder struct {
+	fset   *token.FileSet
+	errors diagnostics.ErrorList
+	values map[ast.Expression]any
+}
+
+// fold returns a literal of the value of expr if it is constant, and expr
+// otherwise. Literals are returned as is, to preserve their source text.
+func (f *folder) fold(expr ast.Expression) ast.Expression {
+	switch expr.(type) {
+	case *ast.IntLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.BooleanLiteral:
+		return expr
+	}
+
+	if v := f.eval(expr); v != nil {
+		if lit := literal(v, expr.Pos()); lit != nil {
+			return lit
+		}
+	}
+
+	return expr
+}
+
+func (f *folder) foldList(list []ast.Expression) {
+	for i, expr := range list {
+		list[i] = f.fold(expr)
+	}
+}
+
+// eval returns the value of the constant expression expr, or nil if expr
+// isn't constant or can't be evaluated.
+func (f *folder) eval(expr ast.Expression) any {
+	if v, ok := f.values[expr]; ok {
+		return v
+	}
+
+	v := f.evalExpr(expr)
+	f.values[expr] = v
+	return v
+}
+
+func (f *folder) evalExpr(expr ast.Expression) any {
+	switch expr := expr.(type) {
+	case *ast.IntLiteral:
+		return expr.Value
+	case *ast.FloatLiteral:
+		return expr.Value
+	case *ast.StringLiteral:
+		return expr.Value
+	case *ast.BooleanLiteral:
+		return expr.Value
+	case *ast.GroupExpression:
+		return f.eval(expr.Right)
+	case *ast.UnaryExpression:
+		right := f.eval(expr.Right)
+		if right == nil {
+			return nil
+		}
+
+		v, err := UnaryOp(expr.Operator.Type, right)
+		return f.result(expr.Pos(), v, err)
+	case *ast.BinaryExpression:
+		left, right := f.eval(expr.Left), f.eval(expr.Right)
+		if left == nil || right == nil {
+			return nil
+		}
+
+		v, err := BinaryOp(expr.Operator.Type, left, right)
+		return f.result(expr.Operator.Pos, v, err)
+	case *ast.LogicalExpression:
+		left := f.eval(expr.Left)
+		if left == nil {
+			return nil
+		}
+
+		// the right operand isn't evaluated, like at runtime, if the
+		// result is decided by the left operand
+		switch expr.Operator.Type {
+		case token.LogicalAnd:
+			if !Truthy(left) {
+				return false
+			}
+		case token.LogicalOr:
+			if Truthy(left) {
+				return true
+			}
+		default:
+			return nil
+		}
+
+		right := f.eval(expr.Right)
+		if right == nil {
+			return nil
+		}
+
+		return Truthy(right)
+	}
+
+	return nil
+}
+
+// result returns the value v of an operation, or reports the error err of
+// the operation as a warning at the position pos and returns nil. Warnings
+// point to the operators of binary expressions, like the errors of the
+// interpreter.
+func (f *folder) result(pos token.Pos, v any, err error) any {
+	if err != nil {
+		if f.fset != nil {
+			f.errors = append(f.errors, &diagnostics.Error{
+				Position: f.fset.Position(pos),
+				Severity: diagnostics.SeverityWarning,
+				Code:     diagnostics.CodeConstant,
+				Message:  err.Error(),
+			})
+		}
+		return nil
+	}
+
+	return v
+}
+
+// literal returns a literal of the constant value v at the position pos, or
+// nil if v can't be represented by a literal.
+func literal(v any, pos token.Pos) ast.Expression {
+	switch v := v.(type) {
+	case int64:
+		if v == math.MinInt64 {
+			// the literal would overflow when it is parsed again
+			return nil
+		}
+
+		return &ast.IntLiteral{
+			Token: token.Token{Type: token.Int, Literal: strconv.FormatInt(v, 10), Pos: pos},
+			Value: v,
+		}
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil
+		}
+
+		// make sure the literal is lexed as a float
+		lit := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(lit, ".e") {
+			lit += ".0"
+		}
+
+		return &ast.FloatLiteral{
+			Token: token.Token{Type: token.Float, Literal: lit, Pos: pos},
+			Value: v,
+		}
+	case string:
+		return &ast.StringLiteral{
+			Token:  token.Token{Type: token.String, Literal: strconv.Quote(v), Pos: pos},
+			Value:  v,
+			Quoted: true,
+		}
+	case bool:
+		return &ast.BooleanLiteral{
+			Token: token.Token{Type: token.Identifier, Literal: strconv.FormatBool(v), Pos: pos},
+			Value: v,
+		}
+	}
+
+	return nil
+}
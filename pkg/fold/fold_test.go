@@ -0,0 +1,187 @@
+package fold_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/diagnostics"
+	"laptudirm.com/x/mash/pkg/fold"
+	"laptudirm.com/x/mash/pkg/interp"
+	"laptudirm.com/x/mash/pkg/parser"
+	"laptudirm.com/x/mash/pkg/printer"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+func parse(t *testing.T, fset *token.FileSet, src string) *ast.Program {
+	t.Helper()
+
+	program, err := parser.Parse(fset, "test.mash", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return program
+}
+
+// right returns the right operand of the assignment in the let statement
+// which src consists of.
+func right(t *testing.T, fset *token.FileSet, src string) ast.Expression {
+	t.Helper()
+
+	program := parse(t, fset, src)
+	return program.Statements[0].(*ast.LetStatement).Expression.(*ast.AssignExpression).Right
+}
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"let mask = 1 << 12 | 0x0f", "let mask = 4111"},
+		{"let x = -(2 + 3) * 2", "let x = -10"},
+		{"let x = 2 * 3.5 + 1", "let x = 8.0"},
+		{"let x = 1e300 * 1e300", "let x = 1e300 * 1e300"},
+		{`let x = "a" + "b"`, `let x = "ab"`},
+		{"let x = 1 < 2 && !0", "let x = true"},
+		{"let x = 0 && 1 / 0", "let x = false"},
+		{"let x = y + (1 + 2)", "let x = y + 3"},
+		{"let x = true || 1 + 1", "let x = true || 2"},
+		{"let x = f(1 + 1)[2 * 2]", "let x = f(2)[4]"},
+		{"let x = [1 + 1, 'a{2 * 3}']", "let x = [2, 'a{6}']"},
+		{"if 2 > 1 { let x = 1 - 1 }", "if true {\n\tlet x = 0\n}"},
+		{"echo 0x10", "echo 0x10"},
+	}
+
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		program := parse(t, fset, test.src)
+		if err := fold.Fold(fset, program); err != nil {
+			t.Errorf("Fold(%q): %v", test.src, err)
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, fset, program); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := strings.TrimSpace(buf.String()); got != test.want {
+			t.Errorf("Fold(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestFoldError(t *testing.T) {
+	tests := []struct {
+		src    string
+		folded string
+		want   string
+	}{
+		{"let x = 1 / 0", "let x = 1 / 0", "test.mash:1:11: warning: division by zero"},
+		{"let x = 2 + 1 % 0", "let x = 2 + 1 % 0", "test.mash:1:15: warning: division by zero"},
+		{"let x = 1 << -1", "let x = 1 << -1", "test.mash:1:11: warning: negative shift count -1"},
+		{
+			"let x = -(-0x7fffffffffffffff - 1)",
+			"let x = -(-9223372036854775807 - 1)",
+			"test.mash:1:9: warning: integer overflow",
+		},
+		{`let x = "a" - "b"`, `let x = "a" - "b"`, "test.mash:1:13: warning: invalid operation: string - string"},
+		{
+			"let x = y\nif x { let y = 1 / 0 }",
+			"let x = y\nif x {\n\tlet y = 1 / 0\n}",
+			"test.mash:2:18: warning: division by zero",
+		},
+	}
+
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		program := parse(t, fset, test.src)
+		err := fold.Fold(fset, program)
+		if err == nil {
+			t.Errorf("Fold(%q) = nil, want %q", test.src, test.want)
+			continue
+		}
+
+		if got := err.Error(); got != test.want {
+			t.Errorf("Fold(%q) = %q, want %q", test.src, got, test.want)
+		}
+
+		// the expressions which can't be evaluated are left as is
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, fset, program); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := strings.TrimSpace(buf.String()); got != test.folded {
+			t.Errorf("Fold(%q) = %q, want %q", test.src, got, test.folded)
+		}
+	}
+}
+
+func TestConstant(t *testing.T) {
+	tests := []struct {
+		src  string
+		want any
+		ok   bool
+	}{
+		{"let x = 1 << 12 | 0x0f", int64(4111), true},
+		{"let x = 1 / 2.0", 0.5, true},
+		{`let x = "a" + "b"`, "ab", true},
+		{"let x = 1 == 1.0", true, true},
+		{"let x = 0 && y", false, true},
+		{"let x = 1 && y", nil, false},
+		{"let x = 1 / 0", nil, false},
+		{"let x = [1]", nil, false},
+	}
+
+	for _, test := range tests {
+		v, ok := fold.Constant(right(t, token.NewFileSet(), test.src))
+		if ok != test.ok || v != test.want {
+			t.Errorf("Constant(%q) = %v, %t, want %v, %t", test.src, v, ok, test.want, test.ok)
+		}
+	}
+}
+
+// message returns the first warning of the diagnostics.ErrorList err in the
+// form of the errors of the interpreter, which have no severity.
+func message(err error) string {
+	e := err.(diagnostics.ErrorList)[0]
+	return fmt.Sprintf("%s: %s", &e.Position, e.Message)
+}
+
+// TestInterp checks that constant expressions are folded into the values
+// which the interpreter evaluates them to, or fail with the same error.
+func TestInterp(t *testing.T) {
+	exprs := []string{
+		"7 / 2", "-7 % 3", "7.0 / 2", "5.5 % 2", "1 << 63", "-1 >> 1",
+		"6 &^ 3", "^0", "+1.5", "1 == 1.0", `"a" != "a"`, `"a" <= "b"`,
+		"1 < 1.5", "!0.0", `!""`, "0 || 2", "1 && 0.0",
+		"0x7fffffffffffffff + 1", "-0x7fffffffffffffff - 2", "3037000500 * 3037000500",
+		"1 / 0", "1.0 % 0", "1 << -1", `"a" * 2`, "1.5 & 1", `-"a"`, "^1.5",
+	}
+
+	for _, src := range exprs {
+		src = "let x = " + src
+
+		in := interp.New()
+		want, err := in.Eval(right(t, in.Fset, src))
+
+		fset := token.NewFileSet()
+		ferr := fold.Fold(fset, parse(t, fset, src))
+		v, ok := fold.Constant(right(t, fset, src))
+
+		switch {
+		case err != nil:
+			if ferr == nil || message(ferr) != err.Error() {
+				t.Errorf("%q: folding error %v, interpreter error %v", src, ferr, err)
+			}
+		case !ok || ferr != nil:
+			t.Errorf("%q: not folded (%v), interpreter value %s", src, ferr, want)
+		case fmt.Sprint(v) != want.String():
+			t.Errorf("%q: folded into %v, interpreter value %s", src, v, want)
+		}
+	}
+}
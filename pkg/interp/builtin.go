@@ -0,0 +1,154 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// builtins is the list of builtin functions which are defined in the
+// global scope of every interpreter.
+var builtins = []*Builtin{
+	{Name: "len", Fn: builtinLen},
+	{Name: "getenv", Fn: builtinGetenv},
+	{Name: "int", Fn: builtinInt},
+	{Name: "float", Fn: builtinFloat},
+}
+
+// builtinCommand is a command implemented by the interpreter, which is
+// executed with the arguments args and the streams s, and returns it's
+// exit status.
+type builtinCommand func(in *Interpreter, args []string, s stdio) int
+
+// builtinCommands maps the names of builtin commands, which are looked up
+// before the commands in PATH, to their implementations.
+var builtinCommands map[string]builtinCommand
+
+func init() {
+	// initialized here since builtins like source and type refer back to
+	// builtinCommands
+	builtinCommands = map[string]builtinCommand{
+		"cd":     cmdCd,
+		"pwd":    cmdPwd,
+		"export": cmdExport,
+		"unset":  cmdUnset,
+		"exit":   cmdExit,
+		"source": cmdSource,
+		".":      cmdSource,
+		"type":   cmdType,
+		"which":  cmdWhich,
+
+		"jobs": cmdJobs,
+		"wait": cmdWait,
+		"kill": cmdKill,
+	}
+}
+
+// builtinLen returns the length of a string, array, or object.
+func builtinLen(in *Interpreter, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument, received %d", len(args))
+	}
+
+	switch v := args[0].(type) {
+	case String:
+		return Int(len(v)), nil
+	case *Array:
+		return Int(len(v.Elements)), nil
+	case *Object:
+		return Int(len(v.Entries)), nil
+	default:
+		return nil, fmt.Errorf("invalid argument of type %s", v.Type())
+	}
+}
+
+// builtinGetenv returns the value of an environment variable, or nil if
+// the variable is not set, unlike $NAME which expands to an empty string.
+func builtinGetenv(in *Interpreter, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument, received %d", len(args))
+	}
+
+	name, ok := args[0].(String)
+	if !ok {
+		return nil, fmt.Errorf("invalid argument of type %s", args[0].Type())
+	}
+
+	val, ok := in.env[string(name)]
+	if !ok {
+		return Nil{}, nil
+	}
+
+	return String(val), nil
+}
+
+// builtinInt converts a number or a string into an integer. Floats are
+// truncated towards zero, and strings are parsed as decimal integers, so
+// that command output like "08" isn't treated as an octal literal.
+func builtinInt(in *Interpreter, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument, received %d", len(args))
+	}
+
+	switch v := args[0].(type) {
+	case Int:
+		return v, nil
+	case Float:
+		i, ok := toInt(v)
+		if !ok {
+			return nil, fmt.Errorf("%s overflows int", v)
+		}
+
+		return i, nil
+	case String:
+		i, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, fmt.Errorf("%q overflows int", v)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", v)
+		}
+
+		return Int(i), nil
+	default:
+		return nil, fmt.Errorf("invalid argument of type %s", v.Type())
+	}
+}
+
+// builtinFloat converts a number or a string into a float.
+func builtinFloat(in *Interpreter, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument, received %d", len(args))
+	}
+
+	switch v := args[0].(type) {
+	case Int:
+		return Float(v), nil
+	case Float:
+		return v, nil
+	case String:
+		f, err := strconv.ParseFloat(strings.TrimSpace(string(v)), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %q", v)
+		}
+
+		return Float(f), nil
+	default:
+		return nil, fmt.Errorf("invalid argument of type %s", v.Type())
+	}
+}
@@ -0,0 +1,533 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+// Exit statuses of commands which could not be started.
+const (
+	StatusNotExecutable = 126 // command found but could not be executed
+	StatusNotFound      = 127 // command not found
+)
+
+// stdio represents the standard streams of a command.
+type stdio struct {
+	in  io.Reader
+	out io.Writer
+	err io.Writer
+}
+
+// stdio returns the interpreter's standard streams.
+func (in *Interpreter) stdio() stdio {
+	return stdio{
+		in:  in.Stdin,
+		out: in.Stdout,
+		err: in.Stderr,
+	}
+}
+
+// execCmdStatement executes the command of stmt and stores it's exit
+// status in the status variable of the global scope. Commands which are
+// run in the background are started as a job instead.
+func (in *Interpreter) execCmdStatement(stmt *ast.CmdStatement) error {
+	if stmt.Background.Type == token.And {
+		in.startJob(stmt)
+		return nil
+	}
+
+	status, err := in.execCommand(stmt.Command, in.stdio())
+	if err != nil {
+		return err
+	}
+
+	in.setStatus(status)
+	return nil
+}
+
+// setStatus sets the exit status of the last command to status.
+func (in *Interpreter) setStatus(status int) {
+	in.status = status
+	in.global.define("status", Int(status))
+}
+
+// execCommand executes cmd using the streams s, and returns it's exit
+// status.
+func (in *Interpreter) execCommand(cmd ast.Command, s stdio) (int, error) {
+	switch cmd := cmd.(type) {
+	case *ast.LogicalCommand:
+		return in.execLogicalCommand(cmd, s)
+	case *ast.UnaryCommand:
+		status, err := in.execCommand(cmd.Right, s)
+		if err != nil {
+			return 0, err
+		}
+
+		// invert the exit status
+		if status == 0 {
+			return 1, nil
+		}
+
+		return 0, nil
+	case *ast.BinaryCommand, *ast.LiteralCommand, *ast.SubshellCommand:
+		return in.execPipeline(cmd, s)
+	default:
+		return 0, in.errorf(cmd, "unknown command %T", cmd)
+	}
+}
+
+// execLogicalCommand executes the left command of cmd, and then executes
+// the right command only if the left one's exit status does not decide
+// the exit status of cmd.
+func (in *Interpreter) execLogicalCommand(cmd *ast.LogicalCommand, s stdio) (int, error) {
+	status, err := in.execCommand(cmd.Left, s)
+	if err != nil {
+		return 0, err
+	}
+
+	switch cmd.Operator.Type {
+	case token.LogicalAnd:
+		if status != 0 {
+			return status, nil
+		}
+	case token.LogicalOr:
+		if status == 0 {
+			return status, nil
+		}
+	default:
+		return 0, in.errorf(cmd, "unknown logical operator %s", cmd.Operator.Type)
+	}
+
+	return in.execCommand(cmd.Right, s)
+}
+
+// pipeline flattens the pipe command cmd into it's stages.
+func pipeline(cmd ast.Command) []ast.Command {
+	if b, ok := cmd.(*ast.BinaryCommand); ok && b.Operator.Type == token.Or {
+		return append(pipeline(b.Left), pipeline(b.Right)...)
+	}
+
+	return []ast.Command{cmd}
+}
+
+// execPipeline starts every stage of the pipeline cmd concurrently, with
+// the standard output of each stage connected to the standard input of the
+// next one, and waits for them to finish. The exit status of a pipeline is
+// the exit status of it's last stage. Builtin commands are executed in
+// subshells if the pipeline has more than one stage.
+func (in *Interpreter) execPipeline(cmd ast.Command, s stdio) (int, error) {
+	stages := pipeline(cmd)
+	procs := make([]process, len(stages))
+	statuses := make([]int, len(stages))
+
+	var err error
+	var pipe *os.File // read end of the previous stage's pipe
+
+	for i, stage := range stages {
+		streams := s
+
+		// ends of pipes which are used by the stage
+		var pipes []*os.File
+		if pipe != nil {
+			streams.in = pipe
+			pipes = append(pipes, pipe)
+			pipe = nil
+		}
+
+		if i < len(stages)-1 {
+			var w *os.File
+			if pipe, w, err = os.Pipe(); err != nil {
+				closeFiles(pipes)
+				err = in.errorf(stage, "%w", err)
+				break
+			}
+
+			streams.out = w
+			pipes = append(pipes, w)
+		}
+
+		if procs[i], statuses[i], err = in.start(stage, streams, pipes, len(stages) > 1); err != nil {
+			break
+		}
+	}
+
+	if pipe != nil && err != nil {
+		pipe.Close()
+	}
+
+	for i, proc := range procs {
+		if proc != nil {
+			statuses[i] = proc.wait()
+		}
+	}
+
+	if in.exit != nil && err == nil {
+		// exit builtin was executed
+		err = in.exit
+	}
+
+	return statuses[len(statuses)-1], err
+}
+
+// process is a started stage of a pipeline.
+type process interface {
+	// wait waits for the process to finish and returns it's exit status.
+	wait() int
+}
+
+// externalProcess is a process started from an executable file.
+type externalProcess struct {
+	cmd *exec.Cmd
+}
+
+func (p externalProcess) wait() int {
+	return exitStatus(p.cmd.Wait())
+}
+
+// internalProcess is a builtin command or a subshell running in it's own
+// goroutine, which sends it's exit status on the channel after it finishes.
+type internalProcess chan int
+
+func (p internalProcess) wait() int {
+	return <-p
+}
+
+// startInternal starts running fn in it's own goroutine, and closes the
+// files after it finishes.
+func startInternal(files []*os.File, fn func() int) internalProcess {
+	done := make(internalProcess, 1)
+	go func() {
+		status := fn()
+		closeFiles(files)
+		done <- status
+	}()
+
+	return done
+}
+
+// start starts the command stage with the streams s, and closes the files
+// in pipes once the stage doesn't need them. If the command could not be
+// started, the error is reported on the standard error and a nil process
+// is returned with the appropriate exit status. Functions are looked up
+// before builtin commands, which are looked up before the commands in PATH.
+// Functions and builtin commands are executed in a subshell if piped is
+// true, so that they don't modify the state of the interpreter while the
+// other stages are being started.
+func (in *Interpreter) start(stage ast.Command, s stdio, pipes []*os.File, piped bool) (process, int, error) {
+	// started processes have their own copies of the files, while builtins
+	// and subshells close them after they finish
+	files, owned := pipes, true
+	defer func() {
+		if owned {
+			closeFiles(files)
+		}
+	}()
+
+	var argv []string
+	var redirects []redirection
+	var err error
+
+	switch stage := stage.(type) {
+	case *ast.LiteralCommand:
+		argv, redirects, err = in.argv(stage)
+	case *ast.SubshellCommand:
+		redirects, err = in.redirections(stage.Redirects)
+	default:
+		return nil, 0, in.errorf(stage, "%T is not supported inside a pipeline", stage)
+	}
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	s, opened, err := redirect(s, in.Dir, redirects)
+	files = append(files, opened...)
+
+	if err != nil {
+		fmt.Fprintln(s.err, err)
+		return nil, 1, nil
+	}
+
+	if cmd, ok := stage.(*ast.SubshellCommand); ok {
+		owned = false
+
+		// the state is copied before any other stage can modify it
+		sub := in.subshell()
+		sub.Stdin, sub.Stdout, sub.Stderr = s.in, s.out, s.err
+
+		return startInternal(files, func() int {
+			return sub.execSubshell(cmd)
+		}), 0, nil
+	}
+
+	// command only consisting of redirections
+	if len(argv) == 0 {
+		return nil, 0, nil
+	}
+
+	_, isFunc := in.function(argv[0])
+	builtin, isBuiltin := builtinCommands[argv[0]]
+
+	if isFunc || isBuiltin {
+		owned = false
+
+		sh := in
+		if piped {
+			sh = in.subshell()
+		}
+
+		return startInternal(files, func() int {
+			if isFunc {
+				return sh.execFunction(stage, argv, s)
+			}
+
+			return builtin(sh, argv, s)
+		}), 0, nil
+	}
+
+	// commands are searched for in the interpreter's PATH, not the
+	// process's
+	path := argv[0]
+	if !strings.Contains(path, "/") {
+		if path, err = in.lookPath(path); err != nil {
+			fmt.Fprintln(s.err, in.errorf(stage, "%w", err))
+			return nil, StatusNotFound, nil
+		}
+	}
+
+	proc := exec.Command(path, argv[1:]...)
+	proc.Args[0] = argv[0]
+	proc.Dir = in.Dir
+	proc.Env = in.environ()
+	proc.Stdin = s.in
+	proc.Stdout = s.out
+	proc.Stderr = s.err
+
+	if err := proc.Start(); err != nil {
+		fmt.Fprintln(s.err, in.errorf(stage, "%w", err))
+
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, StatusNotFound, nil
+		}
+
+		return nil, StatusNotExecutable, nil
+	}
+
+	// processes of background jobs can be killed
+	if in.job != nil {
+		in.job.started(proc.Process)
+	}
+
+	return externalProcess{proc}, 0, nil
+}
+
+// execSubshell executes the statements of cmd in the subshell in, and
+// returns the exit status of it's last command.
+func (in *Interpreter) execSubshell(cmd *ast.SubshellCommand) int {
+	err := in.Run(&ast.Program{Statements: cmd.Statements})
+	return in.subshellStatus(in.status, err)
+}
+
+// function returns the user defined function name, if it is in scope.
+func (in *Interpreter) function(name string) (*Function, bool) {
+	v, _ := in.scope.get(name)
+	fn, ok := v.(*Function)
+	return fn, ok
+}
+
+// execFunction calls the function named argv[0] with the other arguments
+// as strings, and with the streams s as the interpreter's standard streams,
+// and returns it's result converted into an exit status. Runtime errors
+// are reported on the standard error with the exit status 1.
+func (in *Interpreter) execFunction(node ast.Node, argv []string, s stdio) int {
+	fn, _ := in.function(argv[0])
+
+	args := make([]Value, len(argv)-1)
+	for i, arg := range argv[1:] {
+		args[i] = String(arg)
+	}
+
+	prev := in.stdio()
+	in.Stdin, in.Stdout, in.Stderr = s.in, s.out, s.err
+	defer func() {
+		in.Stdin, in.Stdout, in.Stderr = prev.in, prev.out, prev.err
+	}()
+
+	// status of a function which doesn't execute any commands
+	in.status = 0
+
+	v, err := in.call(node, fn, args)
+	if err != nil {
+		return in.subshellStatus(0, err)
+	}
+
+	return in.resultStatus(v)
+}
+
+// resultStatus converts the result v of a function called as a command
+// into an exit status. Integers are used as is, true and false are 0 and 1,
+// and nil is the exit status of the last command executed by the function.
+// Any other value is 0 if it is truthy, and 1 otherwise.
+func (in *Interpreter) resultStatus(v Value) int {
+	switch v := v.(type) {
+	case Nil:
+		return in.status
+	case Int:
+		return int(v)
+	default:
+		if truthy(v) {
+			return 0
+		}
+
+		return 1
+	}
+}
+
+// exitStatus converts the error returned by waiting on a process into the
+// process's exit status.
+func exitStatus(err error) int {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		return exitErr.ExitCode()
+	default:
+		// killed by a signal or failed to copy streams
+		return 1
+	}
+}
+
+// argv evaluates the components of cmd into a list of arguments and a
+// list of redirections. Brace expansions and unquoted glob patterns expand
+// into multiple arguments.
+func (in *Interpreter) argv(cmd *ast.LiteralCommand) ([]string, []redirection, error) {
+	var redirects []redirection
+	argv := make([]string, 0, len(cmd.Components))
+
+	for _, component := range cmd.Components {
+		switch component.(type) {
+		case *ast.Redirect, *ast.Heredoc:
+			r, err := in.redirection(component)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			redirects = append(redirects, r)
+			continue
+		}
+
+		args, err := in.fields(component)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		argv = append(argv, args...)
+	}
+
+	return argv, redirects, nil
+}
+
+// word evaluates the command component c into a single string. The
+// escapes of unquoted strings are removed, like in arguments.
+func (in *Interpreter) word(c ast.CommandComponent) (string, error) {
+	switch c := c.(type) {
+	case *ast.StringLiteral:
+		if !c.Quoted {
+			return unescapeMeta(c.Value), nil
+		}
+
+		return c.Value, nil
+	case *ast.TemplateLiteral:
+		return in.evalTemplate(c)
+	case *ast.Word:
+		var b strings.Builder
+		for _, part := range c.Parts {
+			s, err := in.word(part)
+			if err != nil {
+				return "", err
+			}
+
+			b.WriteString(s)
+		}
+
+		return b.String(), nil
+	case *ast.CommandSubstitution:
+		v, err := in.evalSubst(c)
+		if err != nil {
+			return "", err
+		}
+
+		return v.String(), nil
+	case *ast.TildeExpansion:
+		return in.home(c)
+	case *ast.BraceExpansion, *ast.BraceRange:
+		return "", in.errorf(c, "ambiguous brace expansion")
+	case *ast.EnvExpansion:
+		val, ok := in.env[c.Name]
+		if !ok && c.Required {
+			return "", in.errorf(c, "environment variable %s is not set", c.Name)
+		}
+
+		return val, nil
+	default:
+		return "", in.errorf(c, "unknown command component %T", c)
+	}
+}
+
+// evalSubst executes the command of the substitution c in a subshell with
+// it's standard output, and standard error for $?( substitutions, captured,
+// and stores it's exit status in the status variable of the global scope.
+func (in *Interpreter) evalSubst(c *ast.CommandSubstitution) (Value, error) {
+	var stdout, stderr bytes.Buffer
+
+	s := in.stdio()
+	s.out = &stdout
+	if c.Token.Type == token.SubstResult {
+		s.err = &stderr
+	}
+
+	status, err := in.subshell().execCommand(c.Command, s)
+
+	var exit *ExitError
+	if errors.As(err, &exit) {
+		status, err = exit.Status, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	in.setStatus(status)
+
+	if c.Token.Type != token.SubstResult {
+		return String(strings.TrimRight(stdout.String(), "\n")), nil
+	}
+
+	return &Object{Entries: map[Value]Value{
+		String("stdout"): String(stdout.String()),
+		String("stderr"): String(stderr.String()),
+		String("status"): Int(status),
+	}}, nil
+}
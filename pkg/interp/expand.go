@@ -0,0 +1,192 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interp
+
+import (
+	"fmt"
+	"os/user"
+	"strings"
+
+	"laptudirm.com/x/mash/pkg/ast"
+)
+
+// segment is a part of an expanded command argument. Unquoted segments are
+// interpreted as glob patterns.
+type segment struct {
+	val      string
+	unquoted bool
+}
+
+// field is an expanded command argument which is made up of segments.
+type field []segment
+
+// fields evaluates the command component c into a list of arguments. The
+// brace expansions in c expand it into multiple arguments, and each of them
+// which is an unquoted glob pattern expands to the sorted list of paths
+// matching it.
+func (in *Interpreter) fields(c ast.CommandComponent) ([]string, error) {
+	expanded, err := in.expand(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []string
+	for _, f := range expanded {
+		matches, err := in.glob(c, f)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, matches...)
+	}
+
+	return args, nil
+}
+
+// glob expands the field f of the command component c into the paths
+// matching it, if it is a valid glob pattern. The meta characters of quoted
+// and expanded segments are escaped in the pattern, and the escapes of the
+// unquoted segments are removed if f isn't expanded.
+func (in *Interpreter) glob(c ast.CommandComponent, f field) ([]string, error) {
+	var isGlob bool
+	var val, pattern strings.Builder
+
+	for _, s := range f {
+		if s.unquoted {
+			isGlob = isGlob || hasMeta(s.val)
+			val.WriteString(unescapeMeta(s.val))
+			pattern.WriteString(s.val)
+		} else {
+			val.WriteString(s.val)
+			pattern.WriteString(escapeMeta(s.val))
+		}
+	}
+
+	if !isGlob {
+		return []string{val.String()}, nil
+	}
+
+	matches, err := glob(in.Dir, pattern.String())
+	if err != nil {
+		// invalid patterns, like the "[" command, are used as is
+		return []string{val.String()}, nil
+	}
+
+	if len(matches) > 0 {
+		return matches, nil
+	}
+
+	switch in.NoMatch {
+	case NoMatchEmpty:
+		return nil, nil
+	case NoMatchError:
+		return nil, in.errorf(c, "no matches found: %s", val.String())
+	default:
+		return []string{val.String()}, nil
+	}
+}
+
+// expand evaluates the command component c into the fields produced by
+// it's brace expansions.
+func (in *Interpreter) expand(c ast.CommandComponent) ([]field, error) {
+	switch c := c.(type) {
+	case *ast.StringLiteral:
+		return []field{{{val: c.Value, unquoted: !c.Quoted}}}, nil
+	case *ast.Word:
+		fields := []field{nil}
+		for _, part := range c.Parts {
+			expanded, err := in.expand(part)
+			if err != nil {
+				return nil, err
+			}
+
+			// every field is followed by every expansion of the part
+			var next []field
+			for _, f := range fields {
+				for _, e := range expanded {
+					next = append(next, append(f[:len(f):len(f)], e...))
+				}
+			}
+
+			fields = next
+		}
+
+		return fields, nil
+	case *ast.BraceExpansion:
+		var fields []field
+		for _, elem := range c.Elements {
+			expanded, err := in.expand(elem)
+			if err != nil {
+				return nil, err
+			}
+
+			fields = append(fields, expanded...)
+		}
+
+		return fields, nil
+	case *ast.BraceRange:
+		var fields []field
+		for _, val := range braceRange(c) {
+			fields = append(fields, field{{val: val}})
+		}
+
+		return fields, nil
+	default:
+		val, err := in.word(c)
+		if err != nil {
+			return nil, err
+		}
+
+		return []field{{{val: val}}}, nil
+	}
+}
+
+// braceRange returns the values of the brace range r.
+func braceRange(r *ast.BraceRange) []string {
+	incr := r.Incr
+	if r.From > r.To {
+		incr = -incr
+	}
+
+	var vals []string
+	for i := r.From; incr > 0 && i <= r.To || incr < 0 && i >= r.To; i += incr {
+		if r.Chars {
+			vals = append(vals, string(rune(i)))
+		} else {
+			vals = append(vals, fmt.Sprintf("%0*d", r.Width, i))
+		}
+	}
+
+	return vals
+}
+
+// home evaluates the tilde expansion t into the home directory of it's
+// user. The expansions of unknown users are left as is.
+func (in *Interpreter) home(t *ast.TildeExpansion) (string, error) {
+	if t.User == "" {
+		dir, err := in.homeDir()
+		if err != nil {
+			return "", in.errorf(t, "%w", err)
+		}
+
+		return dir, nil
+	}
+
+	u, err := user.Lookup(t.User)
+	if err != nil {
+		return t.Token.Literal, nil
+	}
+
+	return u.HomeDir, nil
+}
@@ -0,0 +1,543 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/fold"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+// eval evaluates expr in the current scope and returns it's value.
+func (in *Interpreter) eval(expr ast.Expression) (Value, error) {
+	switch expr := expr.(type) {
+	case *ast.AssignExpression:
+		return in.evalAssign(expr)
+	case *ast.LogicalExpression:
+		return in.evalLogical(expr)
+	case *ast.BinaryExpression:
+		return in.evalBinary(expr)
+	case *ast.UnaryExpression:
+		return in.evalUnary(expr)
+	case *ast.GroupExpression:
+		return in.eval(expr.Right)
+	case *ast.CallExpression:
+		return in.evalCall(expr)
+	case *ast.GetExpression:
+		return in.evalGet(expr)
+	case *ast.SelectorExpression:
+		return in.evalSelector(expr)
+	case *ast.VariableExpression:
+		if v, ok := in.scope.get(expr.Name.Literal); ok {
+			return v, nil
+		}
+
+		return nil, in.errorf(expr, "undefined variable %s", expr.Name.Literal)
+
+	case *ast.IntLiteral:
+		return Int(expr.Value), nil
+	case *ast.FloatLiteral:
+		return Float(expr.Value), nil
+	case *ast.StringLiteral:
+		return String(expr.Value), nil
+	case *ast.BooleanLiteral:
+		return Boolean(expr.Value), nil
+	case *ast.FunctionLiteral:
+		return &Function{
+			Literal: expr,
+			scope:   in.scope,
+		}, nil
+	case *ast.ArrayLiteral:
+		return in.evalArray(expr)
+	case *ast.ObjectLiteral:
+		return in.evalObject(expr)
+	case *ast.TemplateLiteral:
+		s, err := in.evalTemplate(expr)
+		return String(s), err
+	case *ast.CommandSubstitution:
+		return in.evalSubst(expr)
+	default:
+		return nil, in.errorf(expr, "unknown expression %T", expr)
+	}
+}
+
+// assignOps maps the compound assignment operators to the binary operators
+// they are composed of.
+var assignOps = map[token.Type]token.Type{
+	token.AdditionAssign:       token.Addition,
+	token.SubtractionAssign:    token.Subtraction,
+	token.MultiplicationAssign: token.Multiplication,
+	token.QuotientAssign:       token.Quotient,
+	token.RemainderAssign:      token.Remainder,
+	token.AndAssign:            token.And,
+	token.OrAssign:             token.Or,
+	token.XorAssign:            token.Xor,
+	token.ShiftLeftAssign:      token.ShiftLeft,
+	token.ShiftRightAssign:     token.ShiftRight,
+	token.AndNotAssign:         token.AndNot,
+}
+
+func (in *Interpreter) evalAssign(expr *ast.AssignExpression) (Value, error) {
+	var current Value
+	op, compound := assignOps[expr.Operator.Type]
+	if compound {
+		var err error
+		if current, err = in.eval(expr.Left); err != nil {
+			return nil, err
+		}
+	}
+
+	value, err := in.eval(expr.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	if compound {
+		if value, err = binaryOp(op, current, value); err != nil {
+			return nil, in.errorf(expr, "%w", err)
+		}
+	}
+
+	return value, in.assign(expr.Left, value, expr.Operator.Type == token.Define)
+}
+
+// assign sets the value of target to v. If define is true, target must be
+// a variable, which is created in the current scope. Otherwise, variables
+// which are not defined in any scope are created in the current scope.
+func (in *Interpreter) assign(target ast.Assignable, v Value, define bool) error {
+	if variable, ok := target.(*ast.VariableExpression); ok {
+		name := variable.Name.Literal
+		if define || !in.scope.assign(name, v) {
+			in.scope.define(name, v)
+		}
+
+		return nil
+	}
+
+	if define {
+		return in.errorf(target, "non-variable on left side of :=")
+	}
+
+	var container, key Value
+	var err error
+
+	switch target := target.(type) {
+	case *ast.GetExpression:
+		if container, err = in.eval(target.Expr); err != nil {
+			return err
+		}
+
+		if key, err = in.eval(target.Name); err != nil {
+			return err
+		}
+	case *ast.SelectorExpression:
+		if container, err = in.eval(target.Name); err != nil {
+			return err
+		}
+
+		key = String(target.Index.Literal)
+	default:
+		return in.errorf(target, "invalid assignment target %T", target)
+	}
+
+	if err := setIndex(container, key, v); err != nil {
+		return in.errorf(target, "%w", err)
+	}
+
+	return nil
+}
+
+func (in *Interpreter) evalLogical(expr *ast.LogicalExpression) (Value, error) {
+	left, err := in.eval(expr.Left)
+	if err != nil {
+		return nil, err
+	}
+
+	// short circuit if the result is decided by the left operand
+	switch expr.Operator.Type {
+	case token.LogicalAnd:
+		if !truthy(left) {
+			return Boolean(false), nil
+		}
+	case token.LogicalOr:
+		if truthy(left) {
+			return Boolean(true), nil
+		}
+	default:
+		return nil, in.errorf(expr, "unknown logical operator %s", expr.Operator.Type)
+	}
+
+	right, err := in.eval(expr.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	return Boolean(truthy(right)), nil
+}
+
+func (in *Interpreter) evalBinary(expr *ast.BinaryExpression) (Value, error) {
+	left, err := in.eval(expr.Left)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := in.eval(expr.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := binaryOp(expr.Operator.Type, left, right)
+	if err != nil {
+		return nil, in.errorf(expr, "%w", err)
+	}
+
+	return v, nil
+}
+
+// binaryOp applies the binary operator op to the operands left and right.
+// The operators are implemented by package fold, which is used to evaluate
+// constant expressions before they are run.
+func binaryOp(op token.Type, left, right Value) (Value, error) {
+	v, err := fold.BinaryOp(op, constant(left), constant(right))
+	if err != nil {
+		return nil, err
+	}
+
+	return fromConstant(v), nil
+}
+
+// toInt converts the float f into an integer by truncating it towards zero,
+// and reports whether the result can be represented by an int64.
+func toInt(f Float) (Int, bool) {
+	t := math.Trunc(float64(f))
+	if math.IsNaN(t) || t < math.MinInt64 || t >= math.MaxInt64 {
+		return 0, false
+	}
+
+	return Int(t), true
+}
+
+func (in *Interpreter) evalUnary(expr *ast.UnaryExpression) (Value, error) {
+	right, err := in.eval(expr.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := unaryOp(expr.Operator.Type, right)
+	if err != nil {
+		return nil, in.errorf(expr, "%w", err)
+	}
+
+	return v, nil
+}
+
+// unaryOp applies the unary operator op to the operand v.
+func unaryOp(op token.Type, v Value) (Value, error) {
+	r, err := fold.UnaryOp(op, constant(v))
+	if err != nil {
+		return nil, err
+	}
+
+	return fromConstant(r), nil
+}
+
+func (in *Interpreter) evalCall(expr *ast.CallExpression) (Value, error) {
+	callee, err := in.eval(expr.Callee)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]Value, len(expr.Arguments))
+	for i, arg := range expr.Arguments {
+		if args[i], err = in.eval(arg); err != nil {
+			return nil, err
+		}
+	}
+
+	return in.call(expr, callee, args)
+}
+
+// call calls the function fn with the arguments args. Errors returned by
+// builtin functions are reported at the position of node.
+func (in *Interpreter) call(node ast.Node, fn Value, args []Value) (Value, error) {
+	switch fn := fn.(type) {
+	case *Builtin:
+		v, err := fn.Fn(in, args)
+		if err != nil {
+			return nil, in.errorf(node, "%s: %w", fn.Name, err)
+		}
+
+		return v, nil
+	case *Function:
+		// arguments are provided to the function as the args array,
+		// along with being bound to the function's parameters
+		s := newScope(fn.scope)
+		s.define("args", &Array{Elements: args})
+
+		if err := bind(s, fn.Literal, args); err != nil {
+			return nil, in.errorf(node, "%w", err)
+		}
+
+		err := in.execBlock(fn.Literal.Block, s)
+		if c, ok := err.(*control); ok {
+			if _, ok := c.stmt.(*ast.ReturnStatement); ok {
+				return c.value, nil
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return Nil{}, nil
+	default:
+		return nil, in.errorf(node, "cannot call non-function %s", fn.Type())
+	}
+}
+
+// bind defines the parameters of the function fn in the scope s, with
+// their values taken from args. A function without parameters accepts any
+// number of arguments.
+func bind(s *scope, fn *ast.FunctionLiteral, args []Value) error {
+	params := fn.Parameters
+	if len(params) == 0 {
+		return nil
+	}
+
+	required := len(params)
+	if fn.Variadic {
+		required--
+	}
+
+	switch {
+	case len(args) < required:
+		return fmt.Errorf("not enough arguments in call: have %d, want %d", len(args), required)
+	case len(args) > required && !fn.Variadic:
+		return fmt.Errorf("too many arguments in call: have %d, want %d", len(args), required)
+	}
+
+	for i, param := range params[:required] {
+		s.define(param.Literal, args[i])
+	}
+
+	if fn.Variadic {
+		// variadic parameter collects the remaining arguments
+		rest := make([]Value, len(args)-required)
+		copy(rest, args[required:])
+		s.define(params[required].Literal, &Array{Elements: rest})
+	}
+
+	return nil
+}
+
+func (in *Interpreter) evalGet(expr *ast.GetExpression) (Value, error) {
+	container, err := in.eval(expr.Expr)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := in.eval(expr.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := getIndex(container, key)
+	if err != nil {
+		return nil, in.errorf(expr, "%w", err)
+	}
+
+	return v, nil
+}
+
+func (in *Interpreter) evalSelector(expr *ast.SelectorExpression) (Value, error) {
+	container, err := in.eval(expr.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := container.(*Object); !ok {
+		return nil, in.errorf(expr, "cannot select field %s of %s", expr.Index.Literal, container.Type())
+	}
+
+	return getIndex(container, String(expr.Index.Literal))
+}
+
+// getIndex returns the element of container at the index key. Missing keys
+// of an object have the value nil.
+func getIndex(container, key Value) (Value, error) {
+	switch c := container.(type) {
+	case *Array:
+		i, err := arrayIndex(key, len(c.Elements))
+		if err != nil {
+			return nil, err
+		}
+
+		return c.Elements[i], nil
+	case String:
+		i, err := arrayIndex(key, len(c))
+		if err != nil {
+			return nil, err
+		}
+
+		return c[i : i+1], nil
+	case *Object:
+		if v, ok := c.Entries[objectKey(key)]; ok {
+			return v, nil
+		}
+
+		return Nil{}, nil
+	default:
+		return nil, fmt.Errorf("cannot index %s", container.Type())
+	}
+}
+
+// setIndex sets the element of container at the index key to v.
+func setIndex(container, key, v Value) error {
+	switch c := container.(type) {
+	case *Array:
+		i, err := arrayIndex(key, len(c.Elements))
+		if err != nil {
+			return err
+		}
+
+		c.Elements[i] = v
+	case *Object:
+		c.Entries[objectKey(key)] = v
+	default:
+		return fmt.Errorf("cannot assign to index of %s", container.Type())
+	}
+
+	return nil
+}
+
+// arrayIndex converts key into an index of a sequence of length n.
+func arrayIndex(key Value, n int) (int, error) {
+	i, ok := key.(Int)
+	if !ok {
+		return 0, fmt.Errorf("invalid index of type %s", key.Type())
+	}
+
+	if i < 0 || i >= Int(n) {
+		return 0, fmt.Errorf("index %s out of range [0:%d]", i, n)
+	}
+
+	return int(i), nil
+}
+
+// objectKey returns the key of an object entry which is indexed by v.
+// Floats which are whole numbers are converted into integers, since they
+// are equal to them.
+func objectKey(v Value) Value {
+	if f, ok := v.(Float); ok {
+		if i, ok := toInt(f); ok && Float(i) == f {
+			return i
+		}
+	}
+
+	return v
+}
+
+func (in *Interpreter) evalArray(expr *ast.ArrayLiteral) (Value, error) {
+	elements := make([]Value, len(expr.Elements))
+	for i, element := range expr.Elements {
+		v, err := in.eval(element)
+		if err != nil {
+			return nil, err
+		}
+
+		elements[i] = v
+	}
+
+	return &Array{Elements: elements}, nil
+}
+
+func (in *Interpreter) evalObject(expr *ast.ObjectLiteral) (Value, error) {
+	entries := make(map[Value]Value, len(expr.Elements))
+	for k, v := range expr.Elements {
+		key, err := in.eval(k)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := in.eval(v)
+		if err != nil {
+			return nil, err
+		}
+
+		entries[objectKey(key)] = value
+	}
+
+	return &Object{Entries: entries}, nil
+}
+
+// evalTemplate evaluates the embedded expressions of the template expr and
+// returns the resulting string.
+func (in *Interpreter) evalTemplate(expr *ast.TemplateLiteral) (string, error) {
+	var b strings.Builder
+
+	for i, component := range expr.Components {
+		s, err := unquoteTemplate(component.Literal)
+		if err != nil {
+			return "", &Error{Position: in.Fset.Position(component.Pos), Err: err}
+		}
+
+		b.WriteString(s)
+
+		if i < len(expr.Expressions) {
+			v, err := in.eval(expr.Expressions[i])
+			if err != nil {
+				return "", err
+			}
+
+			b.WriteString(v.String())
+		}
+	}
+
+	return b.String(), nil
+}
+
+// unquoteTemplate interprets the escape sequences in the string part s of
+// a template literal.
+func unquoteTemplate(s string) (string, error) {
+	var b strings.Builder
+
+	for len(s) > 0 {
+		// \{ is only valid inside templates
+		if strings.HasPrefix(s, `\{`) {
+			b.WriteByte('{')
+			s = s[2:]
+			continue
+		}
+
+		r, multibyte, tail, err := strconv.UnquoteChar(s, '\'')
+		if err != nil {
+			return "", err
+		}
+
+		if multibyte {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte(byte(r))
+		}
+
+		s = tail
+	}
+
+	return b.String(), nil
+}
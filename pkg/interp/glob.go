@@ -0,0 +1,250 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interp
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// NoMatch specifies how a glob pattern which doesn't match any file is
+// expanded.
+type NoMatch int
+
+// Behaviours of glob patterns without any matches.
+const (
+	NoMatchLiteral NoMatch = iota // the pattern is used as is
+	NoMatchEmpty                  // the pattern expands to no arguments
+	NoMatchError                  // the pattern is a runtime error
+)
+
+// hasMeta reports whether s contains any unescaped glob meta characters.
+func hasMeta(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+
+	return false
+}
+
+// escapeMeta escapes the glob meta characters in s.
+func escapeMeta(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// unescapeMeta removes the escapes of glob meta characters from s.
+func unescapeMeta(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// glob returns the sorted list of paths matching pattern. The pattern
+// syntax is the one of filepath.Match, and a "**" element of the pattern
+// matches zero or more directories, or, at the end of the pattern, every
+// file and directory below it. Wildcards don't match the leading '.' of
+// hidden files, unless the element of the pattern itself starts with a
+// '.'. Relative patterns are matched inside the working directory wd, and
+// the matched paths are relative to it. The only possible error is
+// filepath.ErrBadPattern.
+func glob(wd, pattern string) ([]string, error) {
+	elems := strings.Split(pattern, "/")
+
+	// paths matched by the pattern so far
+	paths := []string{""}
+	if elems[0] == "" {
+		// absolute pattern
+		paths, elems = []string{"/"}, elems[1:]
+	}
+
+	for i, elem := range elems {
+		last := i == len(elems)-1
+
+		var next []string
+		for _, path := range paths {
+			matches, err := globElem(wd, path, elem, last)
+			if err != nil {
+				return nil, err
+			}
+
+			next = append(next, matches...)
+		}
+
+		if paths = next; len(paths) == 0 {
+			return nil, nil
+		}
+	}
+
+	// "**" may match a path more than once
+	sort.Strings(paths)
+	unique := paths[:1]
+	for _, path := range paths[1:] {
+		if path != unique[len(unique)-1] {
+			unique = append(unique, path)
+		}
+	}
+
+	return unique, nil
+}
+
+// globElem returns the paths inside the directory dir which match the
+// pattern element elem, where dir is relative to the working directory wd.
+// Unless elem is the last element of the pattern, only directories are
+// matched.
+func globElem(wd, dir, elem string, last bool) ([]string, error) {
+	switch {
+	case elem == "":
+		// trailing or repeated slash
+		if isDir(resolve(wd, dir)) {
+			return []string{join(dir, "")}, nil
+		}
+
+		return nil, nil
+	case elem == "**":
+		return globStar(wd, dir, last), nil
+	case !hasMeta(elem):
+		path := join(dir, unescapeMeta(elem))
+		full := resolve(wd, path)
+		if _, err := os.Lstat(full); err != nil || !last && !isDir(full) {
+			return nil, nil
+		}
+
+		return []string{path}, nil
+	}
+
+	// check pattern syntax even if dir can't be read
+	if _, err := filepath.Match(elem, ""); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(resolve(wd, dir))
+	if err != nil {
+		return nil, nil
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(elem, ".") {
+			continue
+		}
+
+		if ok, _ := filepath.Match(elem, name); !ok {
+			continue
+		}
+
+		if path := join(dir, name); last || isDir(resolve(wd, path)) {
+			matches = append(matches, path)
+		}
+	}
+
+	return matches, nil
+}
+
+// globStar returns the directory dir along with every directory below it.
+// If last is true, every file below dir is returned instead, excluding dir.
+// Hidden files and directories are skipped.
+func globStar(wd, dir string, last bool) []string {
+	var matches []string
+	if !last {
+		matches = append(matches, dir)
+	}
+
+	root := resolve(wd, dir)
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return nil
+		}
+
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if last || d.IsDir() {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return nil
+			}
+
+			matches = append(matches, join(dir, filepath.ToSlash(rel)))
+		}
+
+		return nil
+	})
+
+	return matches
+}
+
+// join joins the matched path dir with the name of a file inside it.
+func join(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+
+	if strings.HasSuffix(dir, "/") {
+		return dir + name
+	}
+
+	return dir + "/" + name
+}
+
+// resolve returns the path of the file name relative to the working
+// directory wd. An empty name refers to wd itself, and an empty wd to the
+// current directory of the process.
+func resolve(wd, name string) string {
+	switch {
+	case name == "" && wd == "":
+		return "."
+	case name == "":
+		return wd
+	case wd == "" || filepath.IsAbs(name):
+		return name
+	default:
+		return filepath.Join(wd, name)
+	}
+}
+
+// isDir reports whether path is a directory, following symlinks.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,217 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package interp implements a tree-walking interpreter which executes the
+// abstract syntax tree of a mash program.
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+// Interpreter represents the state of an executing mash program. The state
+// is preserved between calls to Run, so a program can be executed in
+// multiple parts.
+type Interpreter struct {
+	Stdin  io.Reader // standard input of commands
+	Stdout io.Writer // standard output of commands
+	Stderr io.Writer // standard error of commands
+
+	// Dir is the working directory of commands. If Dir is empty, the
+	// current directory of the process is used.
+	Dir string
+
+	// Fset is the file set of the executed programs, which is used to
+	// resolve the positions of runtime errors.
+	Fset *token.FileSet
+
+	// NoMatch specifies how glob patterns in command arguments which
+	// don't match any file are expanded.
+	NoMatch NoMatch
+
+	global *scope // global scope
+	scope  *scope // current scope
+
+	status int // exit status of the last command
+
+	jobs *jobTable // background jobs
+	job  *job      // job being executed by a subshell
+
+	env     map[string]string // environment variables of commands
+	prevDir string            // previous working directory, for cd -
+	exit    *ExitError        // set by the exit builtin to end the program
+}
+
+// New creates a new Interpreter which uses the standard streams and a copy
+// of the environment of the current process, and has the builtin values
+// defined in it's global scope.
+func New() *Interpreter {
+	global := newScope(nil)
+	global.define("nil", Nil{})
+	global.define("true", Boolean(true))
+	global.define("false", Boolean(false))
+	global.define("status", Int(0))
+	global.define("job", Int(0))
+
+	for _, b := range builtins {
+		global.define(b.Name, b)
+	}
+
+	return &Interpreter{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+
+		Fset: token.NewFileSet(),
+
+		global: global,
+		scope:  global,
+
+		jobs: &jobTable{},
+		env:  parseEnviron(os.Environ()),
+	}
+}
+
+// subshell returns a copy of the interpreter, whose variables, environment,
+// and working directory are isolated from the interpreter's. The job table is shared
+// with the subshell.
+func (in *Interpreter) subshell() *Interpreter {
+	c := newCloner()
+	return &Interpreter{
+		Stdin:  in.Stdin,
+		Stdout: in.Stdout,
+		Stderr: in.Stderr,
+
+		Dir: in.Dir,
+
+		Fset:    in.Fset,
+		NoMatch: in.NoMatch,
+
+		global: c.scope(in.global),
+		scope:  c.scope(in.scope),
+
+		status:  in.status,
+		env:     cloneEnv(in.env),
+		prevDir: in.prevDir,
+
+		jobs: in.jobs,
+		job:  in.job,
+	}
+}
+
+// Run executes the statements of program in the interpreter's global
+// scope. It stops at, and returns, the first runtime error, or an
+// *ExitError if the program is ended by the exit builtin.
+func (in *Interpreter) Run(program *ast.Program) error {
+	err := in.execStatements(program.Statements)
+	if c, ok := err.(*control); ok {
+		// break, continue, or return outside of it's context
+		return in.errorf(c.stmt, "%s", c)
+	}
+
+	return err
+}
+
+// Eval evaluates the expression expr in the interpreter's global scope,
+// and returns it's value.
+func (in *Interpreter) Eval(expr ast.Expression) (Value, error) {
+	return in.eval(expr)
+}
+
+// Define defines a variable name with the value v in the interpreter's
+// global scope.
+func (in *Interpreter) Define(name string, v Value) {
+	in.global.define(name, v)
+}
+
+// Status returns the exit status of the last command statement executed
+// by the interpreter.
+func (in *Interpreter) Status() int {
+	return in.status
+}
+
+// Error represents a runtime error, along with the position in the source
+// of the node which caused it.
+type Error struct {
+	Position token.Position
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", &e.Position, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ExitError is returned by Run when the program is ended by the exit
+// builtin command, and contains the exit status of the program.
+type ExitError struct {
+	Status int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Status)
+}
+
+// subshellStatus returns the exit status of a subshell whose execution
+// ended with the exit status status and the error err. Runtime errors are
+// reported on the standard error with the exit status 1.
+func (in *Interpreter) subshellStatus(status int, err error) int {
+	var exit *ExitError
+	switch {
+	case errors.As(err, &exit):
+		return exit.Status
+	case err != nil:
+		fmt.Fprintln(in.Stderr, err)
+		return 1
+	default:
+		return status
+	}
+}
+
+// errorf creates a new runtime Error at the position of node, with the
+// message formatted according to format.
+func (in *Interpreter) errorf(node ast.Node, format string, a ...any) error {
+	return &Error{
+		Position: in.Fset.Position(position(node)),
+		Err:      fmt.Errorf(format, a...),
+	}
+}
+
+// position returns the position in the source which an error caused by
+// node should point to. Errors in operations point to their operators,
+// while errors in other nodes point to the start of the node.
+func position(node ast.Node) token.Pos {
+	switch n := node.(type) {
+	case *ast.AssignExpression:
+		return n.Operator.Pos
+	case *ast.LogicalExpression:
+		return n.Operator.Pos
+	case *ast.BinaryExpression:
+		return n.Operator.Pos
+	case *ast.CallExpression:
+		return n.Parenthesis.Pos
+	case *ast.SelectorExpression:
+		return n.Index.Pos
+	}
+
+	return node.Pos()
+}
@@ -0,0 +1,452 @@
+package interp
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/parser"
+)
+
+func parse(t *testing.T, in *Interpreter, src string) *ast.Program {
+	t.Helper()
+
+	program, err := parser.Parse(in.Fset, "test.mash", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return program
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		src  string
+		name string
+		want string
+	}{
+		{"let x = 1 + 2 * 3", "x", "7"},
+		{"let x = (1 + 2) * 3", "x", "9"},
+		{"let x = 7 % 4", "x", "3"},
+		{"let x = 6 & 3 | 8", "x", "10"},
+		{"let x = 1 << 4", "x", "16"},
+		{"let x = -2", "x", "-2"},
+		{`let x = "a" + "b"`, "x", "ab"},
+		{`let x = "a" < "b"`, "x", "true"},
+		{"let x = 1 == 1 && 2 != 2", "x", "false"},
+		{"let x = nil || 1", "x", "true"},
+		{"let x = !0", "x", "true"},
+		{"let x = 1\nlet x += 2", "x", "3"},
+		{"let x = [1, 2, 3]\nlet x[1] = 5", "x", "[1, 5, 3]"},
+		{"let x = obj[\"a\": 1]\nlet x.b = 2", "x", `obj["a": 1, "b": 2]`},
+		{"let o = obj[\"a\": [1, 2]]\nlet x = o.a[1]", "x", "2"},
+		{"let y = 2\nlet x = 'y is {y * 2}\\n'", "x", "y is 4\n"},
+		{"let x = len([1, 2])", "x", "2"},
+		{"let x = 0\nif x == 0 { let x = 1 } else { let x = 2 }", "x", "1"},
+		{"let x = 0\nif x { let x = 1 } else if x == 0 { let x = 2 }", "x", "2"},
+		{"let x = 0\nfor x < 10 { let x += 3 }", "x", "12"},
+		{"let x = 0\nif true { let x := 5 }", "x", "0"},
+		{"let x = 0\nlet f = func { let x = args[0] }\nlet f(4)", "x", "4"},
+		{"let x = 0\nlet f = func(a, b) { let x = a - b }\nlet f(5, 3)", "x", "2"},
+		{"let x = 0\nfunc set(v) { let x = v }\nlet set(7)", "x", "7"},
+		{"let x = 0\nfunc f(a, rest...) { let x = len(rest) }\nlet f(1, 2, 3)", "x", "2"},
+		{"let x = 0\nfunc f(rest...) { let x = rest }\nlet f()", "x", "[]"},
+		{"let x = 0\nfor { let x += 1\nif x == 5 { break } }", "x", "5"},
+		{"let x = 0\nlet i = 0\nfor i < 5 { let i += 1\nif i % 2 { continue }\nlet x += i }", "x", "6"},
+		{"func f(a) { for { return a * 2 } }\nlet x = f(3)", "x", "6"},
+		{"func f() { return }\nlet x = f()", "x", "nil"},
+		{"let x = 7 / 2", "x", "3"},
+		{"let x = -7 / 2 + -7 % 2", "x", "-4"},
+		{"let x = 7 / 2.0", "x", "3.5"},
+		{"let x = 0.5 + 1", "x", "1.5"},
+		{"let x = 0x10 + 0o10 + 010 + 0b10 + 1_000", "x", "1034"},
+		{"let x = 1e3", "x", "1000"},
+		{"let x = 0x7fff_ffff_ffff_ffff", "x", "9223372036854775807"},
+		{"let x = 1 << 63 >> 63", "x", "-1"},
+		{"let x = ^0", "x", "-1"},
+		{"let x = 1 == 1.0 && 2 < 2.5", "x", "true"},
+		{"let o = obj[1: \"a\"]\nlet x = o[1.0]", "x", "a"},
+		{"let x = [int(2.9), int(-2.9), int(\" 42\\n\"), float(1) / 2, float(\"1e2\")]", "x", "[2, -2, 42, 0.5, 100]"},
+	}
+
+	for _, test := range tests {
+		in := New()
+		if err := in.Run(parse(t, in, test.src)); err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+
+		v, ok := in.scope.get(test.name)
+		if !ok {
+			t.Errorf("%q: variable %s not defined", test.src, test.name)
+			continue
+		}
+
+		if v.String() != test.want {
+			t.Errorf("%q: expected %s, received %s", test.src, test.want, v)
+		}
+	}
+}
+
+func TestRunError(t *testing.T) {
+	tests := []struct {
+		src  string
+		line int
+		col  int
+	}{
+		{"let x = y", 1, 9},
+		{"let x = 1\nlet x = x / 0", 2, 11},
+		{`let x = 1 + "a"`, 1, 11},
+		{"let x = [1]\nlet y = x[2]", 2, 9},
+		{"let x = 1\nlet x()", 2, 6},
+		{"let x = 1.5 << 2", 1, 13},
+		{"let x = 2.0 & 1", 1, 13},
+		{"let x = 0x7fff_ffff_ffff_ffff\nlet x = x + 1", 2, 11},
+		{"let x = -0x7fff_ffff_ffff_ffff - 1\nlet x = x * -1", 2, 11},
+		{"let x = -0x7fff_ffff_ffff_ffff - 1\nlet y = -x", 2, 9},
+		{"let x = [1]\nlet y = x[0.0]", 2, 9},
+		{"let x = int(\"0x10\")", 1, 12},
+		{"let x = int(1e19)", 1, 12},
+		{"let x = \"é\" - 1", 1, 13},
+		{"func f(a, b) {}\nlet f(1)", 2, 6},
+		{"func f(a) {}\nlet f(1, 2)", 2, 6},
+	}
+
+	for _, test := range tests {
+		in := New()
+		err := in.Run(parse(t, in, test.src))
+
+		var rerr *Error
+		if !errors.As(err, &rerr) {
+			t.Errorf("%q: expected runtime error, received %v", test.src, err)
+			continue
+		}
+
+		if rerr.Position.Line != test.line || rerr.Position.Col != test.col {
+			t.Errorf("%q: expected error at %d:%d, received %s", test.src, test.line, test.col, rerr)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		src    string
+		out    string
+		status int
+	}{
+		{`echo "a b" c`, "a b c\n", 0},
+		{"let x = 2\necho 'x={x}'", "x=2\n", 0},
+		{"echo a | cat | cat", "a\n", 0},
+		{"false", "", 1},
+		{"! false", "", 0},
+		{"false && echo a", "", 1},
+		{"false || echo a", "a\n", 0},
+		{"true && echo a || echo b", "a\n", 0},
+		{"false\necho 'status={status}'", "status=1\n", 0},
+		{`echo $MASH_A/b "$MASH_A ${MASH_A}" "\$MASH_A" x${MASH_UNSET}y`, "a/b a a $MASH_A xy\n", 0},
+		{`echo "$MASH_EMPTY"'{getenv("MASH_EMPTY")}' '{getenv("MASH_UNSET")}'`, " nil\n", 0},
+		{"let x = $(printf 'a\\n\\n')\necho '{x}' $(echo b | cat)c", "a bc\n", 0},
+		{"let r = $?(sh -c 'echo a; echo b >&2; exit 3')\necho '{r.stdout}{r.stderr}{r.status} {status}'", "a\nb\n3 3\n", 0},
+		{`echo ~/a ~ "~" x~`, "/home/mash/a /home/mash ~ x~\n", 0},
+		{"echo a{b,c{1..2},}d {c..a} {01..10..3} x{a}y {a..1}", "abd ac1d ac2d ad c b a 01 04 07 10 x{a}y {a..1}\n", 0},
+		{"let x = 1\n(\n\tlet x = 2\n\techo '{x}'\n)\necho '{x}'", "2\n1\n", 0},
+		{"(echo a; echo b) | grep b && (echo c)", "b\nc\n", 0},
+		{"(echo a; false) || echo b", "a\nb\n", 0},
+		{"! (false) | (cat; false)", "", 0},
+		{"(echo a) 2> /dev/null | (cat; sh -c 'exit 4')", "a\n", 4},
+		{"func f(a) { echo '<{a}>' }\nf x | cat\nf 'y z'", "<x>\n<y z>\n", 0},
+		{"let n = 0\nfunc f() { let n += 1\ncat }\necho a | f\nf <<< b\necho '{n}'", "a\nb\n1\n", 0},
+		{"func f() { return args[0] == \"a\" }\nf a && f b || echo no", "no\n", 0},
+		{"func f() { return 3 }\nf\necho '{status}'\nfunc g() { false }\ng", "3\n", 1},
+		{"func f() {}\nfalse\nf", "", 0},
+		{"func f(a) {}\nf a b", "", 1},
+		{"let len = func { return 7 }\nlen", "", 7},
+	}
+
+	t.Setenv("MASH_A", "a")
+	t.Setenv("MASH_EMPTY", "")
+	t.Setenv("HOME", "/home/mash")
+
+	for _, test := range tests {
+		var out strings.Builder
+
+		in := New()
+		in.Stdout = &out
+		if err := in.Run(parse(t, in, test.src)); err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+
+		if out.String() != test.out {
+			t.Errorf("%q: expected output %q, received %q", test.src, test.out, out.String())
+		}
+
+		if in.Status() != test.status {
+			t.Errorf("%q: expected status %d, received %d", test.src, test.status, in.Status())
+		}
+	}
+}
+
+func TestEnvRequired(t *testing.T) {
+	in := New()
+	err := in.Run(parse(t, in, "echo a${MASH_UNSET?}"))
+
+	var rerr *Error
+	if !errors.As(err, &rerr) || rerr.Position.Col != 7 {
+		t.Errorf("expected error at 1:7 for unset required variable, received %v", err)
+	}
+}
+
+func TestDir(t *testing.T) {
+	src := `echo a > f
+cat f
+ls *
+(pwd)`
+
+	var out strings.Builder
+
+	in := New()
+	in.Stdout = &out
+	in.Dir = t.TempDir()
+	if err := in.Run(parse(t, in, src)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "a\nf\n" + in.Dir + "\n"
+	if out.String() != want {
+		t.Errorf("expected output %q, received %q", want, out.String())
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	dir, _ := filepath.EvalSymlinks(t.TempDir())
+	for _, path := range []string{"a/b", "cdpath/c"} {
+		if err := os.MkdirAll(filepath.Join(dir, path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("CDPATH", filepath.Join(dir, "cdpath"))
+	t.Setenv("MASH_X", "")
+	t.Setenv("MASH_Y", "y")
+
+	tests := []struct {
+		src    string
+		out    string
+		status int
+	}{
+		{"cd a\npwd\ncd b\ncd -\nls", "{dir}/a\n{dir}/a\nb\n", 0},
+		{"(cd a; pwd)\npwd\ncd c\npwd", "{dir}/a\n{dir}\n{dir}/cdpath/c\n{dir}/cdpath/c\n", 0},
+		{"echo x | cd a\npwd\ncd missing", "{dir}\n", 1},
+		{"let v = 1\nexport v MASH_X=2\nunset MASH_Y\nsh -c 'echo $v $MASH_X $MASH_Y.'", "1 2 .\n", 0},
+		{"export 1x=2", "", 1},
+		{"(export MASH_Z=1); echo $MASH_Z\nexport MASH_Z=2 | cat\nsh -c 'echo $MASH_Z.'", "\n.\n", 0},
+		{"(unset MASH_Y; echo $MASH_Y.)\necho $MASH_Y", ".\ny\n", 0},
+		{"echo '#!/bin/sh\necho found' > cmd\nsh -c 'chmod +x cmd'\nexport PATH=.\ncmd\ntype cmd", "found\ncmd is ./cmd\n", 0},
+		{"echo 'let s = 1\necho sourced' > f\n. f > g\ncat g\necho '{s}'", "sourced\n1\n", 0},
+		{"func f() {}\ntype f cd sh", "f is a function\ncd is a shell builtin\nsh is {sh}\n", 0},
+		{"which cd missing-command", "cd: shell builtin\n", 1},
+		{"(exit 3) && echo a\necho '{status}'", "3\n", 0},
+		{"let x = $(exit 4)\nfalse\nexit\necho a", "", 1},
+		{"echo a && exit 5 || echo b\necho c", "a\n", 5},
+		{"echo 'exit 6' > f\nsource f\necho a", "", 6},
+		{"echo 'let y = 0\nlet x = y && 1 / 0\necho ok' > f\nsource f", "ok\n", 0},
+	}
+
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		var out, stderr strings.Builder
+
+		in := New()
+		in.Stdout = &out
+		in.Stderr = &stderr
+		in.Dir = dir
+
+		var status int
+		var exit *ExitError
+
+		switch err := in.Run(parse(t, in, test.src)); {
+		case errors.As(err, &exit):
+			status = exit.Status
+		case err != nil:
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		default:
+			status = in.Status()
+		}
+
+		want := strings.NewReplacer("{dir}", dir, "{sh}", sh).Replace(test.out)
+		if out.String() != want {
+			t.Errorf("%q: expected output %q, received %q", test.src, want, out.String())
+		}
+
+		if status != test.status {
+			t.Errorf("%q: expected status %d, received %d", test.src, test.status, status)
+		}
+	}
+}
+
+func TestJobs(t *testing.T) {
+	src := `sleep 10 &
+let slow = job
+let x = 1
+func set() {
+	let x = 2
+	return x
+}
+sh -c 'exit $0' '{set()}' &
+wait '{job}'
+echo 'x={x} status={status}'
+jobs
+kill '%{slow}'
+wait %1
+echo 'killed={status != 0}'
+wait %1 2> /dev/null
+echo 'no job {status}'
+jobs`
+
+	var out strings.Builder
+
+	in := New()
+	in.Stdout = &out
+	if err := in.Run(parse(t, in, src)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "x=1 status=2\n[1] Running\tsleep 10\nkilled=true\nno job 127\n"
+	if out.String() != want {
+		t.Errorf("expected output %q, received %q", want, out.String())
+	}
+}
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		src     string
+		out     string
+		noMatch NoMatch
+	}{
+		{"echo *.go", "a.go b.go\n", NoMatchLiteral},
+		{`echo "*.go" '*'.go`, "*.go *.go\n", NoMatchLiteral},
+		{"echo ?.txt [ab].go", "c.txt a.go b.go\n", NoMatchLiteral},
+		{"echo .*.go", ".hidden.go\n", NoMatchLiteral},
+		{"echo **/*.go", "a.go b.go sub/d.go sub/deep/e.go\n", NoMatchLiteral},
+		{"echo sub/** */", "sub/d.go sub/deep sub/deep/e.go sub/\n", NoMatchLiteral},
+		{"echo $MASH_STAR.go $MASH_A*.go", "*.go a.go\n", NoMatchLiteral},
+		{"echo *.md", "*.md\n", NoMatchLiteral},
+		{"echo x *.md", "x\n", NoMatchEmpty},
+		{`echo a\*.go \[ab].go`, "a*.go [ab].go\n", NoMatchEmpty},
+		{`echo a\;b \;`, "a;b ;\n", NoMatchLiteral},
+		{"echo [ a[b", "[ a[b\n", NoMatchEmpty},
+		{"[ 1 = 1 ] && echo ok", "ok\n", NoMatchError},
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.txt", ".hidden.go", "sub/d.go", "sub/deep/e.go"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	t.Setenv("MASH_STAR", "*")
+	t.Setenv("MASH_A", "a")
+
+	for _, test := range tests {
+		var out strings.Builder
+
+		in := New()
+		in.Stdout = &out
+		in.NoMatch = test.noMatch
+		if err := in.Run(parse(t, in, test.src)); err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+
+		if out.String() != test.out {
+			t.Errorf("%q: expected output %q, received %q", test.src, test.out, out.String())
+		}
+	}
+
+	in := New()
+	in.NoMatch = NoMatchError
+	if err := in.Run(parse(t, in, "echo *.md")); err == nil {
+		t.Errorf("expected error for glob pattern without matches")
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	tests := []struct {
+		src string
+		out string
+	}{
+		{"echo a > '{dir}/f'\ncat '{dir}/f'", "a\n"},
+		{"echo a > '{dir}/f'\necho b >> '{dir}/f'\ncat < '{dir}/f'", "a\nb\n"},
+		{"ls '{dir}/missing' 2> '{dir}/f'\ncat '{dir}/f' | wc -l", "1\n"},
+		{"ls '{dir}/missing' &> '{dir}/f' || echo failed", "failed\n"},
+		{"ls '{dir}/missing' 2>&1 | wc -l", "1\n"},
+		{"cat <<< hello", "hello\n"},
+		{"cat <<< a\\;b", "a;b\n"},
+		{"echo x > '{dir}'/my\\ file\ncat '{dir}/my file'", "x\n"},
+		{"cat <<EOF\na {b}\nEOF", "a {b}\n"},
+		{"let b = 1\ncat <<'EOF' | cat\na {b} \\{b}\nEOF", "a 1 {b}\n"},
+		{"cat <<-EOF\n\t\ta\n\tEOF", "a\n"},
+	}
+
+	for _, test := range tests {
+		var out strings.Builder
+
+		in := New()
+		in.Stdout = &out
+		in.Define("dir", String(t.TempDir()))
+
+		if err := in.Run(parse(t, in, test.src)); err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+
+		if got := strings.TrimSpace(out.String()) + "\n"; got != test.out {
+			t.Errorf("%q: expected output %q, received %q", test.src, test.out, got)
+		}
+	}
+
+	// the target of an invalid redirection must not be created
+	var stderr strings.Builder
+
+	dir := t.TempDir()
+	in := New()
+	in.Stderr = &stderr
+	in.Define("dir", String(dir))
+	if err := in.Run(parse(t, in, "echo a 3> '{dir}/f'")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(stderr.String(), "file descriptor 3") {
+		t.Errorf("expected error for redirection to file descriptor 3, received %q", stderr.String())
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "f")); !os.IsNotExist(err) {
+		t.Errorf("invalid redirection created it's target: %v", err)
+	}
+}
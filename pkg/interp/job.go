@@ -0,0 +1,330 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"laptudirm.com/x/mash/pkg/ast"
+)
+
+// job represents a command statement running in the background.
+type job struct {
+	id  int
+	cmd string // source of the command
+
+	mu      sync.Mutex
+	procs   []*os.Process // processes started by the job
+	pending os.Signal     // signal for the next process of the job
+
+	done   chan struct{} // closed after the job finishes
+	status int           // exit status of the job, set before done is closed
+}
+
+// started records that the job has started the process p, and sends the
+// pending signal of the job to it, if there is one.
+func (j *job) started(p *os.Process) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.procs = append(j.procs, p)
+	if j.pending != nil {
+		p.Signal(j.pending)
+		j.pending = nil
+	}
+}
+
+// signal sends sig to every running process of the job. If none of the
+// processes are running, sig is sent to the next process started by the
+// job instead.
+func (j *job) signal(sig os.Signal) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	running := false
+	for _, p := range j.procs {
+		switch err := p.Signal(sig); {
+		case err == nil:
+			running = true
+		case !errors.Is(err, os.ErrProcessDone):
+			return err
+		}
+	}
+
+	if !running && !j.finished() {
+		j.pending = sig
+	}
+
+	return nil
+}
+
+// finished reports whether the job has finished.
+func (j *job) finished() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait waits for the job to finish and returns it's exit status.
+func (j *job) wait() int {
+	<-j.done
+	return j.status
+}
+
+// state returns a description of the state of the job.
+func (j *job) state() string {
+	switch {
+	case !j.finished():
+		return "Running"
+	case j.status == 0:
+		return "Done"
+	default:
+		return fmt.Sprintf("Exit %d", j.status)
+	}
+}
+
+// jobTable is the table of the background jobs of an interpreter, which is
+// shared with it's subshells.
+type jobTable struct {
+	mu   sync.Mutex
+	jobs []*job // sorted by id
+}
+
+// add adds a new job running the command cmd to the table. The id of the
+// job is one more than the largest id in the table.
+func (t *jobTable) add(cmd string) *job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	id := 1
+	if n := len(t.jobs); n > 0 {
+		id = t.jobs[n-1].id + 1
+	}
+
+	j := &job{
+		id:   id,
+		cmd:  cmd,
+		done: make(chan struct{}),
+	}
+
+	t.jobs = append(t.jobs, j)
+	return j
+}
+
+// get returns the job with the given id, or nil if there isn't one.
+func (t *jobTable) get(id int) *job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, j := range t.jobs {
+		if j.id == id {
+			return j
+		}
+	}
+
+	return nil
+}
+
+// list returns the jobs in the table.
+func (t *jobTable) list() []*job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*job(nil), t.jobs...)
+}
+
+// remove removes the job j from the table.
+func (t *jobTable) remove(j *job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range t.jobs {
+		if t.jobs[i] == j {
+			t.jobs = append(t.jobs[:i], t.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// startJob starts executing the command of stmt in the background, in a
+// subshell, and defines the job variable to the id of the new job.
+func (in *Interpreter) startJob(stmt *ast.CmdStatement) {
+	j := in.jobs.add(in.source(stmt.Command))
+
+	sub := in.subshell()
+	sub.job = j
+
+	go func() {
+		defer close(j.done)
+
+		status, err := sub.execCommand(stmt.Command, sub.stdio())
+		j.status = sub.subshellStatus(status, err)
+	}()
+
+	in.global.define("job", Int(j.id))
+	in.setStatus(0)
+}
+
+// source returns the source code of node.
+func (in *Interpreter) source(node ast.Node) string {
+	f := in.Fset.File(node.Pos())
+	if f == nil {
+		return ""
+	}
+
+	return f.Source()[f.Offset(node.Pos()):f.Offset(node.End())]
+}
+
+// jobID parses the job id in the argument arg, of the form %id or, if
+// bare is true, id.
+func jobID(arg string, bare bool) (int, bool) {
+	if !strings.HasPrefix(arg, "%") && !bare {
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(arg, "%"))
+	return id, err == nil
+}
+
+// cmdJobs implements the jobs builtin, which lists the background jobs
+// and their states. Finished jobs are removed after they are listed.
+func cmdJobs(in *Interpreter, args []string, s stdio) int {
+	if len(args) > 1 {
+		fmt.Fprintln(s.err, "jobs: too many arguments")
+		return 2
+	}
+
+	for _, j := range in.jobs.list() {
+		fmt.Fprintf(s.out, "[%d] %s\t%s\n", j.id, j.state(), j.cmd)
+		if j.finished() {
+			in.jobs.remove(j)
+		}
+	}
+
+	return 0
+}
+
+// cmdWait implements the wait builtin, which waits for the jobs with the
+// given ids, written as %id or id, to finish and exits with the status of
+// the last one. Without any arguments, it waits for every job and exits
+// with a zero status. Waited jobs are removed from the job table.
+func cmdWait(in *Interpreter, args []string, s stdio) int {
+	if len(args) == 1 {
+		for _, j := range in.jobs.list() {
+			j.wait()
+			in.jobs.remove(j)
+		}
+
+		return 0
+	}
+
+	status := 0
+	for _, arg := range args[1:] {
+		id, ok := jobID(arg, true)
+		if !ok {
+			fmt.Fprintf(s.err, "wait: invalid job id %s\n", arg)
+			status = 2
+			continue
+		}
+
+		j := in.jobs.get(id)
+		if j == nil {
+			fmt.Fprintf(s.err, "wait: no such job %s\n", arg)
+			status = StatusNotFound
+			continue
+		}
+
+		status = j.wait()
+		in.jobs.remove(j)
+	}
+
+	return status
+}
+
+// signals maps the names of the signals accepted by kill to their values.
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
+
+// cmdKill implements the kill builtin, which sends a signal, SIGTERM by
+// default, to the jobs, written as %id, or the processes with the given
+// ids. The signal is given as the first argument, written as -NAME or
+// -number.
+func cmdKill(in *Interpreter, args []string, s stdio) int {
+	args = args[1:]
+
+	sig := syscall.SIGTERM
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		name := strings.TrimPrefix(strings.TrimPrefix(args[0], "-"), "SIG")
+		if n, err := strconv.Atoi(name); err == nil {
+			sig = syscall.Signal(n)
+		} else if sig = signals[strings.ToUpper(name)]; sig == 0 {
+			fmt.Fprintf(s.err, "kill: unknown signal %s\n", args[0])
+			return 2
+		}
+
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		fmt.Fprintln(s.err, "kill: expected a job or process id")
+		return 2
+	}
+
+	status := 0
+	for _, arg := range args {
+		if err := in.kill(arg, sig); err != nil {
+			fmt.Fprintf(s.err, "kill: %s: %s\n", arg, err)
+			status = 1
+		}
+	}
+
+	return status
+}
+
+// kill sends sig to the job or process with the id arg.
+func (in *Interpreter) kill(arg string, sig os.Signal) error {
+	if id, ok := jobID(arg, false); ok {
+		j := in.jobs.get(id)
+		if j == nil {
+			return errors.New("no such job")
+		}
+
+		return j.signal(sig)
+	}
+
+	pid, err := strconv.Atoi(arg)
+	if err != nil {
+		return errors.New("invalid process id")
+	}
+
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+
+	return p.Signal(sig)
+}
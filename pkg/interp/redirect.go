@@ -0,0 +1,241 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+// redirection represents a redirect of a command whose target has been
+// evaluated.
+type redirection struct {
+	op     token.Type // redirection operator
+	fd     int        // redirected file descriptor
+	target string     // file, file descriptor, or string
+	pos    token.Position
+}
+
+// redirect applies the redirections in order to the streams s, and returns
+// the new streams along with the files opened for them, which should be
+// closed by the caller after the command has been started. Relative file
+// names are resolved against the working directory wd.
+func redirect(s stdio, wd string, redirects []redirection) (stdio, []*os.File, error) {
+	var files []*os.File
+
+	for _, r := range redirects {
+		var err error
+		switch op := r.op; op {
+		case token.RedirectIn:
+			if err = checkInput(r.fd); err != nil {
+				break
+			}
+
+			var f *os.File
+			if f, err = os.Open(resolve(wd, r.target)); err == nil {
+				files = append(files, f)
+				err = s.setInput(r.fd, f)
+			}
+		case token.HereString:
+			err = s.setInput(r.fd, strings.NewReader(r.target+"\n"))
+		case token.Heredoc:
+			err = s.setInput(r.fd, strings.NewReader(r.target))
+		case token.RedirectOut, token.RedirectAppend, token.RedirectAll:
+			flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			if op == token.RedirectAppend {
+				flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+			}
+
+			// check the descriptor before the file is created or truncated
+			if op != token.RedirectAll {
+				if err = checkOutput(r.fd); err != nil {
+					break
+				}
+			}
+
+			var f *os.File
+			if f, err = os.OpenFile(resolve(wd, r.target), flag, 0o666); err != nil {
+				break
+			}
+
+			files = append(files, f)
+			if op == token.RedirectAll {
+				s.out, s.err = f, f
+				break
+			}
+
+			err = s.setOutput(r.fd, f)
+		case token.RedirectDup:
+			var fd int
+			if fd, err = strconv.Atoi(r.target); err != nil {
+				err = fmt.Errorf("invalid file descriptor %s", r.target)
+				break
+			}
+
+			switch fd {
+			case 1:
+				err = s.setOutput(r.fd, s.out)
+			case 2:
+				err = s.setOutput(r.fd, s.err)
+			default:
+				err = fmt.Errorf("bad file descriptor %d", fd)
+			}
+		default:
+			err = fmt.Errorf("unknown redirection %s", r.op)
+		}
+
+		if err != nil {
+			return s, files, &Error{Position: r.pos, Err: err}
+		}
+	}
+
+	return s, files, nil
+}
+
+// redirections evaluates the redirects and heredocs in components, and
+// ignores every other component.
+func (in *Interpreter) redirections(components []ast.CommandComponent) ([]redirection, error) {
+	var redirects []redirection
+	for _, c := range components {
+		switch c.(type) {
+		case *ast.Redirect, *ast.Heredoc:
+			r, err := in.redirection(c)
+			if err != nil {
+				return nil, err
+			}
+
+			redirects = append(redirects, r)
+		}
+	}
+
+	return redirects, nil
+}
+
+// redirection evaluates the target of the redirect or heredoc c.
+func (in *Interpreter) redirection(c ast.CommandComponent) (redirection, error) {
+	r := redirection{pos: in.Fset.Position(position(c))}
+
+	var err error
+	switch c := c.(type) {
+	case *ast.Redirect:
+		r.op, r.fd = c.Operator.Type, c.Fd
+		r.target, err = in.word(c.Target)
+	case *ast.Heredoc:
+		r.op, r.fd = c.Operator.Type, c.Fd
+		r.target, err = in.evalHeredoc(c)
+	default:
+		err = in.errorf(c, "%T is not a redirection", c)
+	}
+
+	return r, err
+}
+
+// checkInput reports an error if the input stream fd can't be redirected.
+func checkInput(fd int) error {
+	if fd != 0 {
+		return fmt.Errorf("cannot redirect input to file descriptor %d", fd)
+	}
+
+	return nil
+}
+
+// checkOutput reports an error if the output stream fd can't be redirected.
+func checkOutput(fd int) error {
+	if fd != 1 && fd != 2 {
+		return fmt.Errorf("cannot redirect output to file descriptor %d", fd)
+	}
+
+	return nil
+}
+
+// setInput sets the input stream fd of s to r.
+func (s *stdio) setInput(fd int, r io.Reader) error {
+	if err := checkInput(fd); err != nil {
+		return err
+	}
+
+	s.in = r
+	return nil
+}
+
+// setOutput sets the output stream fd of s to w.
+func (s *stdio) setOutput(fd int, w io.Writer) error {
+	if err := checkOutput(fd); err != nil {
+		return err
+	}
+
+	if fd == 1 {
+		s.out = w
+	} else {
+		s.err = w
+	}
+
+	return nil
+}
+
+// closeFiles closes every file in files.
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// evalHeredoc evaluates the body of the heredoc h.
+func (in *Interpreter) evalHeredoc(h *ast.Heredoc) (string, error) {
+	var b strings.Builder
+
+	for i, component := range h.Components {
+		s := component.Literal
+		if h.Interpolate {
+			s = strings.ReplaceAll(s, `\{`, "{")
+		}
+
+		if h.Indent {
+			// components after an expression don't start a line
+			s = stripTabs(s, i == 0)
+		}
+
+		b.WriteString(s)
+
+		if i < len(h.Expressions) {
+			v, err := in.eval(h.Expressions[i])
+			if err != nil {
+				return "", err
+			}
+
+			b.WriteString(v.String())
+		}
+	}
+
+	return b.String(), nil
+}
+
+// stripTabs removes the leading tabs from every line of s. The first line
+// is only stripped if lineStart is true.
+func stripTabs(s string, lineStart bool) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if i > 0 || lineStart {
+			lines[i] = strings.TrimLeft(line, "\t")
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,134 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interp
+
+// scope represents a lexical scope of variables. Lookups which fail in a
+// scope are continued in it's parent scope.
+type scope struct {
+	parent *scope
+	values map[string]Value
+}
+
+// newScope creates a new empty scope with the provided parent.
+func newScope(parent *scope) *scope {
+	return &scope{
+		parent: parent,
+		values: make(map[string]Value),
+	}
+}
+
+// get looks up the variable name in s and it's parents, and returns it's
+// value. It returns false if the variable is not defined.
+func (s *scope) get(name string) (Value, bool) {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.values[name]; ok {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// define creates a new variable name with the value v in s, shadowing any
+// variables with the same name in it's parents.
+func (s *scope) define(name string, v Value) {
+	s.values[name] = v
+}
+
+// assign sets the value of the nearest variable name in s or it's parents
+// to v. It returns false if the variable is not defined.
+func (s *scope) assign(name string, v Value) bool {
+	for ; s != nil; s = s.parent {
+		if _, ok := s.values[name]; ok {
+			s.values[name] = v
+			return true
+		}
+	}
+
+	return false
+}
+
+// cloner deep copies scopes and the values in them, preserving the sharing
+// between the copied scopes and values.
+type cloner struct {
+	scopes map[*scope]*scope
+	values map[Value]Value
+}
+
+// newCloner creates a new cloner without any copies.
+func newCloner() *cloner {
+	return &cloner{
+		scopes: make(map[*scope]*scope),
+		values: make(map[Value]Value),
+	}
+}
+
+// scope returns the copy of s, which has copies of it's parents and values.
+func (c *cloner) scope(s *scope) *scope {
+	if s == nil {
+		return nil
+	}
+
+	if copied, ok := c.scopes[s]; ok {
+		return copied
+	}
+
+	copied := &scope{values: make(map[string]Value, len(s.values))}
+	c.scopes[s] = copied
+
+	copied.parent = c.scope(s.parent)
+	for name, v := range s.values {
+		copied.values[name] = c.value(v)
+	}
+
+	return copied
+}
+
+// value returns the copy of v. Values which can't be modified are not
+// copied, and functions are copied along with the scopes they were
+// defined in.
+func (c *cloner) value(v Value) Value {
+	if copied, ok := c.values[v]; ok {
+		return copied
+	}
+
+	switch v := v.(type) {
+	case *Array:
+		copied := &Array{Elements: make([]Value, len(v.Elements))}
+		c.values[v] = copied
+
+		for i, element := range v.Elements {
+			copied.Elements[i] = c.value(element)
+		}
+
+		return copied
+	case *Object:
+		copied := &Object{Entries: make(map[Value]Value, len(v.Entries))}
+		c.values[v] = copied
+
+		for key, value := range v.Entries {
+			copied.Entries[c.value(key)] = c.value(value)
+		}
+
+		return copied
+	case *Function:
+		copied := &Function{Literal: v.Literal}
+		c.values[v] = copied
+
+		copied.scope = c.scope(v.scope)
+		return copied
+	default:
+		return v
+	}
+}
@@ -0,0 +1,401 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"laptudirm.com/x/mash/pkg/fold"
+	"laptudirm.com/x/mash/pkg/parser"
+)
+
+// wd returns the absolute path of the interpreter's working directory.
+func (in *Interpreter) wd() (string, error) {
+	if in.Dir == "" {
+		return os.Getwd()
+	}
+
+	return filepath.Abs(in.Dir)
+}
+
+// cmdCd implements the cd builtin, which changes the working directory of
+// the interpreter to the given directory, or the home directory without
+// any arguments. The directory "-" is the previous working directory.
+// Relative directories which don't start with "." are searched for in the
+// directories of CDPATH first. The new working directory is printed if it
+// was found using "-" or CDPATH.
+func cmdCd(in *Interpreter, args []string, s stdio) int {
+	var dir string
+	switch len(args) {
+	case 1:
+		home, err := in.homeDir()
+		if err != nil {
+			fmt.Fprintf(s.err, "cd: %s\n", err)
+			return 1
+		}
+
+		dir = home
+	case 2:
+		dir = args[1]
+	default:
+		fmt.Fprintln(s.err, "cd: too many arguments")
+		return 2
+	}
+
+	wd, err := in.wd()
+	if err != nil {
+		fmt.Fprintf(s.err, "cd: %s\n", err)
+		return 1
+	}
+
+	var found bool
+	if dir == "-" {
+		if in.prevDir == "" {
+			fmt.Fprintln(s.err, "cd: no previous directory")
+			return 1
+		}
+
+		dir, found = in.prevDir, true
+	} else if path, ok := cdpath(in.env["CDPATH"], wd, dir); ok {
+		dir, found = path, true
+	}
+
+	path := resolve(wd, dir)
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		fmt.Fprintf(s.err, "cd: %s: not a directory\n", dir)
+		return 1
+	}
+
+	in.prevDir, in.Dir = wd, path
+	if found {
+		fmt.Fprintln(s.out, path)
+	}
+
+	return 0
+}
+
+// cdpath searches for the directory dir in the directories of the CDPATH
+// list, which are relative to the working directory wd, and returns it's
+// path.
+func cdpath(list, wd, dir string) (string, bool) {
+	if filepath.IsAbs(dir) || strings.HasPrefix(dir, ".") {
+		return "", false
+	}
+
+	for _, base := range filepath.SplitList(list) {
+		if base == "" {
+			// the working directory itself
+			continue
+		}
+
+		if path := resolve(wd, filepath.Join(base, dir)); isDir(path) {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+// homeDir returns the home directory of the current user, which is the
+// value of HOME in the interpreter's environment if it is set.
+func (in *Interpreter) homeDir() (string, error) {
+	if home := in.env["HOME"]; home != "" {
+		return home, nil
+	}
+
+	return os.UserHomeDir()
+}
+
+// cmdPwd implements the pwd builtin, which prints the working directory.
+func cmdPwd(in *Interpreter, args []string, s stdio) int {
+	wd, err := in.wd()
+	if err != nil {
+		fmt.Fprintf(s.err, "pwd: %s\n", err)
+		return 1
+	}
+
+	fmt.Fprintln(s.out, wd)
+	return 0
+}
+
+// parseEnviron converts the environment env, which is in the form returned
+// by os.Environ, into a map from the names of the variables to their values.
+func parseEnviron(env []string) map[string]string {
+	m := make(map[string]string, len(env))
+	for _, v := range env {
+		if name, val, ok := strings.Cut(v, "="); ok {
+			m[name] = val
+		}
+	}
+
+	return m
+}
+
+// cloneEnv returns a copy of the environment env.
+func cloneEnv(env map[string]string) map[string]string {
+	m := make(map[string]string, len(env))
+	for name, val := range env {
+		m[name] = val
+	}
+
+	return m
+}
+
+// environ returns the interpreter's environment in the form "NAME=value",
+// sorted by name, which is the environment of the commands it starts.
+func (in *Interpreter) environ() []string {
+	env := make([]string, 0, len(in.env))
+	for name, val := range in.env {
+		env = append(env, name+"="+val)
+	}
+
+	sort.Strings(env)
+	return env
+}
+
+// validEnvName reports whether name is a valid environment variable name.
+func validEnvName(name string) bool {
+	for i, r := range name {
+		letter := r == '_' || 'A' <= r && r <= 'Z' || 'a' <= r && r <= 'z'
+		if !letter && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+
+	return name != ""
+}
+
+// cmdExport implements the export builtin, which sets the environment
+// variables given as NAME=value. An argument without a value exports the
+// mash variable NAME, converted to a string. Without any arguments, the
+// environment is printed. Subshells have their own copies of the
+// environment, so their exports don't change the interpreter's.
+func cmdExport(in *Interpreter, args []string, s stdio) int {
+	if len(args) == 1 {
+		for _, v := range in.environ() {
+			fmt.Fprintln(s.out, v)
+		}
+
+		return 0
+	}
+
+	status := 0
+	for _, arg := range args[1:] {
+		name, val, ok := strings.Cut(arg, "=")
+		if !validEnvName(name) {
+			fmt.Fprintf(s.err, "export: invalid variable name %s\n", name)
+			status = 1
+			continue
+		}
+
+		if !ok {
+			v, defined := in.scope.get(name)
+			if !defined {
+				fmt.Fprintf(s.err, "export: %s is not defined\n", name)
+				status = 1
+				continue
+			}
+
+			val = v.String()
+		}
+
+		in.env[name] = val
+	}
+
+	return status
+}
+
+// cmdUnset implements the unset builtin, which removes the given variables
+// from the environment.
+func cmdUnset(in *Interpreter, args []string, s stdio) int {
+	status := 0
+	for _, name := range args[1:] {
+		if !validEnvName(name) {
+			fmt.Fprintf(s.err, "unset: invalid variable name %s\n", name)
+			status = 1
+			continue
+		}
+
+		delete(in.env, name)
+	}
+
+	return status
+}
+
+// cmdExit implements the exit builtin, which ends the program with the
+// given exit status, or the exit status of the last command by default.
+func cmdExit(in *Interpreter, args []string, s stdio) int {
+	status := in.status
+	switch len(args) {
+	case 1:
+	case 2:
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintf(s.err, "exit: invalid exit status %s\n", args[1])
+			n = 2
+		}
+
+		status = n & 0xff
+	default:
+		fmt.Fprintln(s.err, "exit: too many arguments")
+		return 2
+	}
+
+	in.exit = &ExitError{Status: status}
+	return status
+}
+
+// cmdSource implements the source builtin, also named ".", which executes
+// the program in the given file in the interpreter's current scope, with
+// the streams of the builtin, and exits with the status of the program's
+// last command.
+func cmdSource(in *Interpreter, args []string, s stdio) int {
+	if len(args) != 2 {
+		fmt.Fprintf(s.err, "%s: expected a file name\n", args[0])
+		return 2
+	}
+
+	src, err := os.ReadFile(resolve(in.Dir, args[1]))
+	if err != nil {
+		fmt.Fprintf(s.err, "%s: %s\n", args[0], err)
+		return 1
+	}
+
+	program, err := parser.Parse(in.Fset, args[1], string(src), 0)
+	if err != nil {
+		fmt.Fprintln(s.err, err)
+		return 2
+	}
+
+	// constant expressions which can't be evaluated are left as is, and
+	// fail only if they are run
+	fold.Fold(in.Fset, program)
+
+	prev := in.stdio()
+	in.Stdin, in.Stdout, in.Stderr = s.in, s.out, s.err
+	defer func() {
+		in.Stdin, in.Stdout, in.Stderr = prev.in, prev.out, prev.err
+	}()
+
+	err = in.Run(program)
+
+	var exit *ExitError
+	switch {
+	case errors.As(err, &exit):
+		// in.exit ends the sourcing program too
+		return exit.Status
+	case err != nil:
+		fmt.Fprintln(s.err, err)
+		return 1
+	default:
+		return in.status
+	}
+}
+
+// kind describes what the command name refers to, and returns the path of
+// the executable file for external commands. Functions are looked up
+// before builtin commands, which are looked up before PATH.
+func (in *Interpreter) kind(name string) (kind, path string, err error) {
+	if _, ok := in.function(name); ok {
+		return "function", "", nil
+	}
+
+	if _, ok := builtinCommands[name]; ok {
+		return "shell builtin", "", nil
+	}
+
+	if strings.Contains(name, "/") {
+		// relative to the working directory, not the process's
+		_, err = exec.LookPath(resolve(in.Dir, name))
+		return "file", name, err
+	}
+
+	path, err = in.lookPath(name)
+	return "file", path, err
+}
+
+// lookPath searches for the executable file name in the directories of
+// PATH in the interpreter's environment, which are relative to it's working
+// directory, and returns it's path. The error is an *exec.Error if the file
+// isn't found.
+func (in *Interpreter) lookPath(name string) (string, error) {
+	for _, dir := range filepath.SplitList(in.env["PATH"]) {
+		path := filepath.Join(dir, name)
+		if dir == "" || dir == "." {
+			// the working directory itself, which is explicit so that
+			// the path isn't searched for again
+			path = "./" + name
+		}
+
+		if isExecutable(resolve(in.Dir, path)) {
+			return path, nil
+		}
+	}
+
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// isExecutable reports whether path is an executable file.
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && info.Mode()&0o111 != 0
+}
+
+// cmdType implements the type builtin, which describes whether each of the
+// given names is a function, a builtin command, or an executable file.
+func cmdType(in *Interpreter, args []string, s stdio) int {
+	status := 0
+	for _, name := range args[1:] {
+		kind, path, err := in.kind(name)
+		switch {
+		case err != nil:
+			fmt.Fprintf(s.err, "type: %s not found\n", name)
+			status = 1
+		case path != "":
+			fmt.Fprintf(s.out, "%s is %s\n", name, path)
+		default:
+			fmt.Fprintf(s.out, "%s is a %s\n", name, kind)
+		}
+	}
+
+	return status
+}
+
+// cmdWhich implements the which builtin, which prints the path of the
+// executable file of each of the given names, or what they are if they
+// are functions or builtin commands.
+func cmdWhich(in *Interpreter, args []string, s stdio) int {
+	status := 0
+	for _, name := range args[1:] {
+		kind, path, err := in.kind(name)
+		switch {
+		case err != nil:
+			fmt.Fprintf(s.err, "which: %s not found\n", name)
+			status = 1
+		case path != "":
+			fmt.Fprintln(s.out, path)
+		default:
+			fmt.Fprintf(s.out, "%s: %s\n", name, kind)
+		}
+	}
+
+	return status
+}
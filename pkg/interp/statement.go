@@ -0,0 +1,139 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interp
+
+import (
+	"laptudirm.com/x/mash/pkg/ast"
+)
+
+// control is returned as an error to unwind the execution of statements
+// on a break, continue, or return statement, until it reaches the loop or
+// the function call which it applies to.
+type control struct {
+	stmt  ast.Statement
+	value Value // result of a return statement
+}
+
+func (c *control) Error() string {
+	switch c.stmt.(type) {
+	case *ast.BreakStatement:
+		return "break is not in a loop"
+	case *ast.ContinueStatement:
+		return "continue is not in a loop"
+	default:
+		return "return is not in a function"
+	}
+}
+
+// execStatements executes a list of statements in the current scope.
+func (in *Interpreter) execStatements(statements []ast.Statement) error {
+	for _, stmt := range statements {
+		if err := in.execStatement(stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (in *Interpreter) execStatement(stmt ast.Statement) error {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		_, err := in.eval(stmt.Expression)
+		return err
+	case *ast.IfStatement:
+		return in.execIfStatement(stmt)
+	case *ast.ForStatement:
+		return in.execForStatement(stmt)
+	case *ast.FuncStatement:
+		in.scope.define(stmt.Name.Literal, &Function{
+			Literal: stmt.Function,
+			scope:   in.scope,
+		})
+		return nil
+	case *ast.BlockStatement:
+		return in.execBlock(stmt, newScope(in.scope))
+	case *ast.CmdStatement:
+		return in.execCmdStatement(stmt)
+	case *ast.BreakStatement, *ast.ContinueStatement:
+		return &control{stmt: stmt}
+	case *ast.ReturnStatement:
+		var v Value = Nil{}
+		if stmt.Result != nil {
+			var err error
+			if v, err = in.eval(stmt.Result); err != nil {
+				return err
+			}
+		}
+
+		return &control{stmt: stmt, value: v}
+	default:
+		return in.errorf(stmt, "unknown statement %T", stmt)
+	}
+}
+
+// execBlock executes the statements of block in the scope s, restoring the
+// interpreter's current scope after it is done.
+func (in *Interpreter) execBlock(block *ast.BlockStatement, s *scope) error {
+	prev := in.scope
+	in.scope = s
+	defer func() { in.scope = prev }()
+
+	return in.execStatements(block.Statements)
+}
+
+func (in *Interpreter) execIfStatement(stmt *ast.IfStatement) error {
+	cond, err := in.eval(stmt.Condition)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case truthy(cond):
+		return in.execBlock(stmt.BlockStmt, newScope(in.scope))
+	case stmt.ElseBlock != nil:
+		return in.execStatement(stmt.ElseBlock)
+	default:
+		return nil
+	}
+}
+
+func (in *Interpreter) execForStatement(stmt *ast.ForStatement) error {
+	for {
+		if stmt.Condition != nil {
+			cond, err := in.eval(stmt.Condition)
+			if err != nil {
+				return err
+			}
+
+			if !truthy(cond) {
+				return nil
+			}
+		}
+
+		err := in.execBlock(stmt.BlockStmt, newScope(in.scope))
+		if c, ok := err.(*control); ok {
+			switch c.stmt.(type) {
+			case *ast.BreakStatement:
+				return nil
+			case *ast.ContinueStatement:
+				continue
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
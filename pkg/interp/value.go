@@ -0,0 +1,171 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interp
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/fold"
+)
+
+// Value interface is implemented by every runtime value of a mash program.
+type Value interface {
+	// Type returns the name of the type of the value.
+	Type() string
+	// String returns the string representation of the value, which is
+	// used when the value is interpolated into a string or a command.
+	String() string
+}
+
+// Nil represents the absence of a value.
+type Nil struct{}
+
+func (n Nil) Type() string   { return "nil" }
+func (n Nil) String() string { return "nil" }
+
+// Boolean represents a truth value.
+type Boolean bool
+
+func (b Boolean) Type() string   { return "boolean" }
+func (b Boolean) String() string { return strconv.FormatBool(bool(b)) }
+
+// Int represents a 64-bit signed integer.
+type Int int64
+
+func (i Int) Type() string   { return "int" }
+func (i Int) String() string { return strconv.FormatInt(int64(i), 10) }
+
+// Float represents a floating point number.
+type Float float64
+
+func (f Float) Type() string { return "float" }
+func (f Float) String() string {
+	return strconv.FormatFloat(float64(f), 'g', -1, 64)
+}
+
+// String represents a string of bytes.
+type String string
+
+func (s String) Type() string   { return "string" }
+func (s String) String() string { return string(s) }
+
+// Array represents an ordered list of values.
+type Array struct {
+	Elements []Value
+}
+
+func (a *Array) Type() string { return "array" }
+func (a *Array) String() string {
+	elements := make([]string, len(a.Elements))
+	for i, element := range a.Elements {
+		elements[i] = Inspect(element)
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// Object represents a mapping of keys to values.
+type Object struct {
+	Entries map[Value]Value
+}
+
+func (o *Object) Type() string { return "object" }
+func (o *Object) String() string {
+	entries := make([]string, 0, len(o.Entries))
+	for key, value := range o.Entries {
+		entries = append(entries, Inspect(key)+": "+Inspect(value))
+	}
+
+	// map iteration order is random
+	sort.Strings(entries)
+	return "obj[" + strings.Join(entries, ", ") + "]"
+}
+
+// Function represents a user defined function, along with the scope it
+// was defined in.
+type Function struct {
+	Literal *ast.FunctionLiteral
+	scope   *scope
+}
+
+func (f *Function) Type() string   { return "function" }
+func (f *Function) String() string { return "func" }
+
+// Builtin represents a function implemented by the interpreter.
+type Builtin struct {
+	Name string
+	Fn   func(in *Interpreter, args []Value) (Value, error)
+}
+
+func (b *Builtin) Type() string   { return "function" }
+func (b *Builtin) String() string { return "func " + b.Name }
+
+// Inspect returns a representation of v which is suitable for displaying
+// it inside a composite value. Strings are quoted, to distinguish them
+// from other values.
+func Inspect(v Value) string {
+	if s, ok := v.(String); ok {
+		return strconv.Quote(string(s))
+	}
+
+	return v.String()
+}
+
+// truthy reports whether v is considered true in a conditional context.
+// The values nil, false, 0, and "" are false, while every other value is
+// true.
+func truthy(v Value) bool {
+	return fold.Truthy(constant(v))
+}
+
+// constant converts v into the representation of constants used by package
+// fold. Values which have no such representation are returned as is.
+func constant(v Value) any {
+	switch v := v.(type) {
+	case Nil:
+		return nil
+	case Boolean:
+		return bool(v)
+	case Int:
+		return int64(v)
+	case Float:
+		return float64(v)
+	case String:
+		return string(v)
+	default:
+		return v
+	}
+}
+
+// fromConstant converts the constant c, which is the result of an operator
+// of package fold, back into a value.
+func fromConstant(c any) Value {
+	switch c := c.(type) {
+	case bool:
+		return Boolean(c)
+	case int64:
+		return Int(c)
+	case float64:
+		return Float(c)
+	case string:
+		return String(c)
+	case Value:
+		return c
+	default:
+		return Nil{}
+	}
+}
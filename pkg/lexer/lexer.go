@@ -14,6 +14,7 @@
 package lexer
 
 import (
+	"context"
 	"errors"
 	"unicode/utf8"
 
@@ -27,25 +28,24 @@ var (
 	ErrEnc = errors.New("illegal utf-8 encoding")
 )
 
-// lexer represents a mash source string and related lexing information.
+// lexer represents a mash source file and related lexing information.
 type lexer struct {
-	src string // source string
-	ch  rune   // current character
-	wd  int    // character width
+	file *token.File // source file
+	src  string      // source string
+	ch   rune        // current character
+	wd   int         // character width
 
 	insertSemi bool
 
-	Tokens TokenStream // lexer token channel
+	heredocs []heredoc // heredocs whose bodies have not been lexed
+
+	tokens []token.Token // emitted tokens which have not been consumed
 
 	err ErrorHandler // lexer errors handling function
 
 	offset   int // offset of the start of the token in the source
 	rdOffset int // offsen of the current rune in the source
 
-	start token.Position // position in the source of the start of the token
-	prev  token.Position // previous position in the source
-	pos   token.Position // position in the source of the current rune
-
 	ErrCount int // number of errors encountered
 }
 
@@ -56,61 +56,102 @@ const (
 
 // ErrorHandler is a function which accepts a position in the source and an
 // error from the lexer and properly handles it.
-//
 type ErrorHandler func(token.Position, error)
 
+// TokenSource is the interface implemented by the sources of the tokens of
+// a mash program, like a Scanner or a TokenStream.
+type TokenSource interface {
+	// Next returns the next token from the source. After the end of the
+	// source, it returns EOF tokens.
+	Next() token.Token
+}
+
+// TokenStream is a channel of tokens which is closed after an EOF token.
 type TokenStream chan token.Token
 
-// Lex starts the lexing of src, using err to handle any lexer errors, and
-// returns the lexer's token channel.
-//
-func Lex(src string, err ErrorHandler) TokenStream {
-	origin := token.Position{
-		Line: 1,
-		Col:  1,
+// Next receives the next token from the stream. It returns an EOF token
+// without a position, NoPos, if the stream is closed.
+func (s TokenStream) Next() token.Token {
+	tok, ok := <-s
+	if !ok {
+		return token.Token{Type: token.Eof}
 	}
 
-	l := &lexer{
-		src: src,
+	return tok
+}
+
+// Lex starts the lexing of the source file in a new goroutine, using err
+// to handle any lexer errors, and returns the lexer's token channel. The
+// goroutine exits after sending an EOF token, so the stream must be read
+// till the end.
+func Lex(file *token.File, err ErrorHandler) TokenStream {
+	return LexContext(context.Background(), file, err)
+}
 
-		Tokens: make(TokenStream),
+// LexContext is like Lex, but the lexing is stopped and the token channel
+// is closed when the context ctx is cancelled, even if the stream has not
+// been read till the end.
+func LexContext(ctx context.Context, file *token.File, err ErrorHandler) TokenStream {
+	s := NewScanner(file, err)
+	tokens := make(TokenStream)
 
-		err: err,
+	go func() {
+		defer close(tokens)
 
-		start: origin,
-		pos:   origin,
-	}
-	go l.run()
+		for {
+			tok := s.Next()
 
-	return l.Tokens
+			select {
+			case tokens <- tok:
+			case <-ctx.Done():
+				return
+			}
+
+			if tok.Type == token.Eof {
+				return
+			}
+		}
+	}()
+
+	return tokens
+}
+
+// newLexer creates a new lexer for the source file, which uses err to
+// handle lexer errors.
+func newLexer(file *token.File, err ErrorHandler) *lexer {
+	return &lexer{
+		file: file,
+		src:  file.Source(),
+
+		err: err,
+	}
 }
 
 // emit emits a token of type t with the current position and literal to
-// the lexer's token channel. It also resets the lexer position and offset
+// the lexer's token buffer. It also resets the lexer position and offset
 // variables.
 func (l *lexer) emit(t token.Type) {
-	l.Tokens <- token.Token{
-		Type:     t,
-		Literal:  l.literal(),
-		Position: l.start,
-	}
+	l.tokens = append(l.tokens, token.Token{
+		Type:    t,
+		Literal: l.literal(),
+		Pos:     l.file.Pos(l.offset),
+	})
 
 	l.ignore()
 }
 
 // error call's the lexer's error handler, if there is one, with the err
-// and the current position, and increases the lexer's ErrorCount by 1.
-//
+// and the position of the current rune, and increases the lexer's
+// ErrorCount by 1.
 func (l *lexer) error(err error) {
 	l.ErrCount++
 	if l.err != nil {
-		l.err(l.pos, err)
+		l.err(l.file.Position(l.file.Pos(l.rdOffset)), err)
 	}
 }
 
 // peek returns the byte right after the current rune. It returns eof if
 // there are no more bytes after the current rune.
-//
 func (l *lexer) peek() rune {
 	if l.atEnd() {
 		return eof
@@ -119,10 +160,9 @@ func (l *lexer) peek() rune {
 	return rune(l.src[l.rdOffset])
 }
 
-// consume consumes the next rune, incresing rdOffset and pos by it's
-// width, and sets ch to the consumed rune. It sets ch to eof if it is at
-// the end of the source.
-//
+// consume consumes the next rune, incresing rdOffset by it's width, and
+// sets ch to the consumed rune. It sets ch to eof if it is at the end of
+// the source.
 func (l *lexer) consume() {
 	if l.atEnd() {
 		l.ch = eof
@@ -160,32 +200,21 @@ advance:
 	l.ch = r
 	l.wd = w
 
-	l.prev = l.pos
-
 	l.rdOffset += w
-	l.pos.Col += w
-
-	if r == '\n' {
-		l.pos.NextLine()
-	}
 }
 
 func (l *lexer) backup() {
 	l.rdOffset -= l.wd
-	l.pos = l.prev
 }
 
 // literal returns a sub-string from the source from offset to rdOffset.
-//
 func (l *lexer) literal() string {
 	return l.src[l.offset:l.rdOffset]
 }
 
-// ignore sets start to pos and offset to rdOffset.
-//
+// ignore sets offset to rdOffset.
 func (l *lexer) ignore() {
 	l.offset = l.rdOffset
-	l.start = l.pos
 }
 
 // atEnd returns true if the rdOffset is greater than the length of the
@@ -86,156 +86,322 @@ echo a command
 `
 
 	tests := []struct {
-		expectedType    token.TokenType
+		expectedType    token.Type
 		expectedLiteral string
 		expectedLine    int
 		expectedCol     int
 	}{
-		{token.COMMENT, "# comment line", 1, 1},
-		{token.FOR, "for", 2, 1},
-		{token.IF, "if", 3, 1},
-		{token.ELIF, "elif", 4, 1},
-		{token.ELSE, "else", 5, 1},
-		{token.LET, "let", 7, 1},
-		{token.FUNC, "func", 8, 1},
-		{token.BREAK, "break", 10, 1},
-		{token.SEMICOLON, "\n", 10, 6},
-		{token.CONTINUE, "continue", 11, 1},
-		{token.SEMICOLON, "\n", 11, 9},
-		{token.RETURN, "return", 12, 1},
-		{token.SEMICOLON, "\n", 12, 7},
-		{token.LET, "let", 14, 1},
-		{token.IDENT, "identifier", 14, 5},
-		{token.SEMICOLON, "\n", 14, 15},
-		{token.LET, "let", 15, 1},
-		{token.FLOAT, "3141592653", 15, 5},
-		{token.SEMICOLON, "\n", 15, 15},
-		{token.LET, "let", 16, 1},
-		{token.STRING, "\"a string\"", 16, 5},
-		{token.SEMICOLON, "\n", 16, 15},
-		{token.COMMENT, "# line comment", 18, 1},
-		{token.LET, "let", 19, 1},
-		{token.IDENT, "i", 19, 5},
-		{token.COMMENT, "# inline", 19, 7},
-		{token.SEMICOLON, "\n", 19, 15},
-		{token.LET, "let", 21, 1},
-		{token.ADD, "+", 21, 5},
-		{token.LET, "let", 22, 1},
-		{token.SUB, "-", 22, 5},
-		{token.LET, "let", 23, 1},
-		{token.MUL, "*", 23, 5},
-		{token.LET, "let", 24, 1},
-		{token.QUO, "/", 24, 5},
-		{token.LET, "let", 25, 1},
-		{token.REM, "%", 25, 5},
-		{token.LET, "let", 27, 1},
-		{token.AND, "&", 27, 5},
-		{token.LET, "let", 28, 1},
-		{token.OR, "|", 28, 5},
-		{token.LET, "let", 29, 1},
-		{token.XOR, "^", 29, 5},
-		{token.LET, "let", 30, 1},
-		{token.SHL, "<<", 30, 5},
-		{token.LET, "let", 31, 1},
-		{token.SHR, ">>", 31, 5},
-		{token.LET, "let", 32, 1},
-		{token.AND_NOT, "&^", 32, 5},
-		{token.LET, "let", 34, 1},
-		{token.ADD_ASSIGN, "+=", 34, 5},
-		{token.LET, "let", 35, 1},
-		{token.SUB_ASSIGN, "-=", 35, 5},
-		{token.LET, "let", 36, 1},
-		{token.MUL_ASSIGN, "*=", 36, 5},
-		{token.LET, "let", 37, 1},
-		{token.QUO_ASSIGN, "/=", 37, 5},
-		{token.LET, "let", 38, 1},
-		{token.REM_ASSIGN, "%=", 38, 5},
-		{token.LET, "let", 40, 1},
-		{token.AND_ASSIGN, "&=", 40, 5},
-		{token.LET, "let", 41, 1},
-		{token.OR_ASSIGN, "|=", 41, 5},
-		{token.LET, "let", 42, 1},
-		{token.XOR_ASSIGN, "^=", 42, 5},
-		{token.LET, "let", 43, 1},
-		{token.SHL_ASSIGN, "<<=", 43, 5},
-		{token.LET, "let", 44, 1},
-		{token.SHR_ASSIGN, ">>=", 44, 5},
-		{token.LET, "let", 45, 1},
-		{token.AND_NOT_ASSIGN, "&^=", 45, 5},
-		{token.LET, "let", 47, 1},
-		{token.LAND, "&&", 47, 5},
-		{token.LET, "let", 48, 1},
-		{token.LOR, "||", 48, 5},
-		{token.LET, "let", 50, 1},
-		{token.EQL, "==", 50, 5},
-		{token.LET, "let", 51, 1},
-		{token.LSS, "<", 51, 5},
-		{token.LET, "let", 52, 1},
-		{token.GTR, ">", 52, 5},
-		{token.LET, "let", 53, 1},
-		{token.ASSIGN, "=", 53, 5},
-		{token.LET, "let", 54, 1},
-		{token.DEFINE, ":=", 54, 5},
-		{token.LET, "let", 55, 1},
-		{token.NOT, "!", 55, 5},
-		{token.LET, "let", 57, 1},
-		{token.NEQ, "!=", 57, 5},
-		{token.LET, "let", 58, 1},
-		{token.LEQ, "<=", 58, 5},
-		{token.LET, "let", 59, 1},
-		{token.GEQ, ">=", 59, 5},
-		{token.LET, "let", 61, 1},
-		{token.LPAREN, "(", 61, 5},
-		{token.LET, "let", 62, 1},
-		{token.LBRACK, "[", 62, 5},
-		{token.LET, "let", 63, 1},
-		{token.LBRACE, "{", 63, 5},
-		{token.LET, "let", 64, 1},
-		{token.COMMA, ",", 64, 5},
-		{token.LET, "let", 66, 1},
-		{token.RPAREN, ")", 66, 5},
-		{token.SEMICOLON, "\n", 66, 6},
-		{token.LET, "let", 67, 1},
-		{token.RBRACK, "]", 67, 5},
-		{token.SEMICOLON, "\n", 67, 6},
-		{token.LET, "let", 68, 1},
-		{token.SEMICOLON, "", 68, 5},
-		{token.RBRACE, "}", 68, 5},
-		{token.SEMICOLON, "\n", 68, 6},
-		{token.LET, "let", 69, 1},
-		{token.SEMICOLON, ";", 69, 5},
-		{token.LET, "let", 70, 1},
-		{token.COLON, ":", 70, 5},
-		{token.BREAK, "break", 72, 1},
-		{token.SEMICOLON, "\n", 72, 6},
-		{token.STRING, "echo", 74, 1},
-		{token.STRING, "a", 74, 6},
-		{token.STRING, "command", 74, 8},
-		{token.SEMICOLON, "\n", 74, 15},
-		{token.LOR, "||", 75, 1},
-		{token.LAND, "&&", 75, 3},
-		{token.NOT, "!", 75, 5},
-		{token.OR, "|", 75, 6},
-		{token.SEMICOLON, "\n", 75, 7},
-		{token.EOF, "", 76, 1},
+		{token.Comment, "# comment line", 1, 1},
+		{token.For, "for", 2, 1},
+		{token.If, "if", 3, 1},
+		{token.Identifier, "elif", 4, 1},
+		{token.Semicolon, "\n", 4, 5},
+		{token.Else, "else", 5, 1},
+		{token.Let, "let", 7, 1},
+		{token.Func, "func", 8, 1},
+		{token.Break, "break", 10, 1},
+		{token.Semicolon, "\n", 10, 6},
+		{token.Continue, "continue", 11, 1},
+		{token.Semicolon, "\n", 11, 9},
+		{token.Return, "return", 12, 1},
+		{token.Semicolon, "\n", 12, 7},
+		{token.Let, "let", 14, 1},
+		{token.Identifier, "identifier", 14, 5},
+		{token.Semicolon, "\n", 14, 15},
+		{token.Let, "let", 15, 1},
+		{token.Int, "3141592653", 15, 5},
+		{token.Semicolon, "\n", 15, 15},
+		{token.Let, "let", 16, 1},
+		{token.String, "\"a string\"", 16, 5},
+		{token.Semicolon, "\n", 16, 15},
+		{token.Comment, "# line comment", 18, 1},
+		{token.Let, "let", 19, 1},
+		{token.Identifier, "i", 19, 5},
+		{token.Comment, "# inline", 19, 7},
+		{token.Semicolon, "\n", 19, 15},
+		{token.Let, "let", 21, 1},
+		{token.Addition, "+", 21, 5},
+		{token.Let, "let", 22, 1},
+		{token.Subtraction, "-", 22, 5},
+		{token.Let, "let", 23, 1},
+		{token.Multiplication, "*", 23, 5},
+		{token.Let, "let", 24, 1},
+		{token.Quotient, "/", 24, 5},
+		{token.Let, "let", 25, 1},
+		{token.Remainder, "%", 25, 5},
+		{token.Let, "let", 27, 1},
+		{token.And, "&", 27, 5},
+		{token.Let, "let", 28, 1},
+		{token.Or, "|", 28, 5},
+		{token.Let, "let", 29, 1},
+		{token.Xor, "^", 29, 5},
+		{token.Let, "let", 30, 1},
+		{token.ShiftLeft, "<<", 30, 5},
+		{token.Let, "let", 31, 1},
+		{token.ShiftRight, ">>", 31, 5},
+		{token.Let, "let", 32, 1},
+		{token.AndNot, "&^", 32, 5},
+		{token.Let, "let", 34, 1},
+		{token.AdditionAssign, "+=", 34, 5},
+		{token.Let, "let", 35, 1},
+		{token.SubtractionAssign, "-=", 35, 5},
+		{token.Let, "let", 36, 1},
+		{token.MultiplicationAssign, "*=", 36, 5},
+		{token.Let, "let", 37, 1},
+		{token.QuotientAssign, "/=", 37, 5},
+		{token.Let, "let", 38, 1},
+		{token.RemainderAssign, "%=", 38, 5},
+		{token.Let, "let", 40, 1},
+		{token.AndAssign, "&=", 40, 5},
+		{token.Let, "let", 41, 1},
+		{token.OrAssign, "|=", 41, 5},
+		{token.Let, "let", 42, 1},
+		{token.XorAssign, "^=", 42, 5},
+		{token.Let, "let", 43, 1},
+		{token.ShiftLeftAssign, "<<=", 43, 5},
+		{token.Let, "let", 44, 1},
+		{token.ShiftRightAssign, ">>=", 44, 5},
+		{token.Let, "let", 45, 1},
+		{token.AndNotAssign, "&^=", 45, 5},
+		{token.Let, "let", 47, 1},
+		{token.LogicalAnd, "&&", 47, 5},
+		{token.Let, "let", 48, 1},
+		{token.LogicalOr, "||", 48, 5},
+		{token.Let, "let", 50, 1},
+		{token.Equal, "==", 50, 5},
+		{token.Let, "let", 51, 1},
+		{token.LessThan, "<", 51, 5},
+		{token.Let, "let", 52, 1},
+		{token.GreaterThan, ">", 52, 5},
+		{token.Let, "let", 53, 1},
+		{token.Assign, "=", 53, 5},
+		{token.Let, "let", 54, 1},
+		{token.Define, ":=", 54, 5},
+		{token.Let, "let", 55, 1},
+		{token.Not, "!", 55, 5},
+		{token.Let, "let", 57, 1},
+		{token.NotEqual, "!=", 57, 5},
+		{token.Let, "let", 58, 1},
+		{token.LessThanEqual, "<=", 58, 5},
+		{token.Let, "let", 59, 1},
+		{token.GreaterThanEqual, ">=", 59, 5},
+		{token.Let, "let", 61, 1},
+		{token.LeftParen, "(", 61, 5},
+		{token.Let, "let", 62, 1},
+		{token.LeftBrack, "[", 62, 5},
+		{token.Let, "let", 63, 1},
+		{token.LeftBrace, "{", 63, 5},
+		{token.Let, "let", 64, 1},
+		{token.Comma, ",", 64, 5},
+		{token.Let, "let", 66, 1},
+		{token.RightParen, ")", 66, 5},
+		{token.Semicolon, "\n", 66, 6},
+		{token.Let, "let", 67, 1},
+		{token.RightBrack, "]", 67, 5},
+		{token.Semicolon, "\n", 67, 6},
+		{token.Let, "let", 68, 1},
+		{token.Semicolon, "", 68, 5},
+		{token.RightBrace, "}", 68, 5},
+		{token.Semicolon, "\n", 68, 6},
+		{token.Let, "let", 69, 1},
+		{token.Semicolon, ";", 69, 5},
+		{token.Let, "let", 70, 1},
+		{token.Colon, ":", 70, 5},
+		{token.Break, "break", 72, 1},
+		{token.Semicolon, "\n", 72, 6},
+		{token.String, "echo", 74, 1},
+		{token.String, "a", 74, 6},
+		{token.String, "command", 74, 8},
+		{token.Semicolon, "\n", 74, 15},
+		{token.LogicalOr, "||", 75, 1},
+		{token.LogicalAnd, "&&", 75, 3},
+		{token.Not, "!", 75, 5},
+		{token.Or, "|", 75, 6},
+		{token.Semicolon, "", 76, 1}, // command continued after trailing |
+		{token.Eof, "", 76, 1},
 	}
 
+	file := token.NewFileSet().AddFile("test.mash", input)
+
 	index := 0
-	for token := range lexer.Lex(input, nil) {
-		t.Logf("%s %s %v\n", &token.Position, token.Type, token.Literal)
-		if token.Type != tests[index].expectedType {
-			t.Fatalf("case %v: expected token type %q, got %q", index, tests[index].expectedType, token.Type)
+	for tok := range lexer.Lex(file, nil) {
+		pos := file.Position(tok.Pos)
+		t.Logf("%s %s %v\n", &pos, tok.Type, tok.Literal)
+		if tok.Type != tests[index].expectedType {
+			t.Fatalf("case %v: expected token type %q, got %q", index, tests[index].expectedType, tok.Type)
+		}
+		if tok.Literal != tests[index].expectedLiteral {
+			t.Fatalf("case %v: expected token literal %q, got %q", index, tests[index].expectedLiteral, tok.Literal)
 		}
-		if token.Literal != tests[index].expectedLiteral {
-			t.Fatalf("case %v: expected token literal %q, got %q", index, tests[index].expectedLiteral, token.Literal)
+		if pos.Line != tests[index].expectedLine {
+			t.Fatalf("case %v: expected token line %d, got %d", index, tests[index].expectedLine, pos.Line)
 		}
-		if token.Position.Line != tests[index].expectedLine {
-			t.Fatalf("case %v: expected token line %d, got %d", index, tests[index].expectedLine, token.Position.Line)
+		if pos.Col != tests[index].expectedCol {
+			t.Fatalf("case %v: expected token col %d, got %d", index, tests[index].expectedCol, pos.Col)
 		}
-		if token.Position.Col != tests[index].expectedCol {
-			t.Fatalf("case %v: expected token col %d, got %d", index, tests[index].expectedCol, token.Position.Col)
+		index++
+	}
+
+}
+
+func TestEnvVar(t *testing.T) {
+	input := "echo $A/b a$B_1 ${C} ${D?}x $ $1 \"$E\" ${F"
+
+	tests := []struct {
+		typ token.Type
+		lit string
+	}{
+		{token.String, "echo"},
+		{token.EnvVar, "$A"},
+		{token.String, "/b"},
+		{token.String, "a"},
+		{token.EnvVar, "$B_1"},
+		{token.EnvVar, "${C}"},
+		{token.EnvVar, "${D?}"},
+		{token.String, "x"},
+		{token.String, "$"},
+		{token.String, "$1"},
+		{token.String, "\"$E\""},
+		{token.Illegal, "${F"},
+		{token.Semicolon, ""},
+		{token.Eof, ""},
+	}
+
+	var errs int
+	file := token.NewFileSet().AddFile("test.mash", input)
+
+	index := 0
+	for tok := range lexer.Lex(file, func(token.Position, error) { errs++ }) {
+		if tok.Type != tests[index].typ || tok.Literal != tests[index].lit {
+			t.Fatalf("case %v: expected %s %q, got %s %q", index, tests[index].typ, tests[index].lit, tok.Type, tok.Literal)
 		}
 		index++
 	}
 
+	if errs != 1 {
+		t.Errorf("expected 1 error for unterminated ${, got %d", errs)
+	}
+}
+
+func TestSubst(t *testing.T) {
+	input := "let x = $(git log | head)\necho $?(a b)c &\n"
+
+	tests := []struct {
+		typ token.Type
+		lit string
+	}{
+		{token.Let, "let"},
+		{token.Identifier, "x"},
+		{token.Assign, "="},
+		{token.Subst, "$("},
+		{token.String, "git"},
+		{token.String, "log"},
+		{token.Or, "|"},
+		{token.String, "head"},
+		{token.RightParen, ")"},
+		{token.Semicolon, "\n"},
+		{token.String, "echo"},
+		{token.SubstResult, "$?("},
+		{token.String, "a"},
+		{token.String, "b"},
+		{token.RightParen, ")"},
+		{token.String, "c"},
+		{token.And, "&"},
+		{token.Semicolon, "\n"},
+		{token.Eof, ""},
+	}
+
+	file := token.NewFileSet().AddFile("test.mash", input)
+
+	index := 0
+	for tok := range lexer.Lex(file, nil) {
+		if tok.Type != tests[index].typ || tok.Literal != tests[index].lit {
+			t.Fatalf("case %v: expected %s %q, got %s %q", index, tests[index].typ, tests[index].lit, tok.Type, tok.Literal)
+		}
+		index++
+	}
+}
+
+func TestSubshell(t *testing.T) {
+	input := "(echo a\\;b; echo b) | (cat \\))\n! (let x = (1)\n)"
+
+	tests := []struct {
+		typ token.Type
+		lit string
+	}{
+		{token.LeftParen, "("},
+		{token.String, "echo"},
+		{token.String, "a\\;b"},
+		{token.Semicolon, ";"},
+		{token.String, "echo"},
+		{token.String, "b"},
+		{token.Semicolon, ""},
+		{token.RightParen, ")"},
+		{token.Or, "|"},
+		{token.LeftParen, "("},
+		{token.String, "cat"},
+		{token.String, "\\)"},
+		{token.Semicolon, ""},
+		{token.RightParen, ")"},
+		{token.Semicolon, "\n"},
+		{token.Not, "!"},
+		{token.LeftParen, "("},
+		{token.Let, "let"},
+		{token.Identifier, "x"},
+		{token.Assign, "="},
+		{token.LeftParen, "("},
+		{token.Int, "1"},
+		{token.RightParen, ")"},
+		{token.Semicolon, "\n"},
+		{token.RightParen, ")"},
+		{token.Semicolon, ""},
+		{token.Eof, ""},
+	}
+
+	file := token.NewFileSet().AddFile("test.mash", input)
+
+	index := 0
+	for tok := range lexer.Lex(file, nil) {
+		if tok.Type != tests[index].typ || tok.Literal != tests[index].lit {
+			t.Fatalf("case %v: expected %s %q, got %s %q", index, tests[index].typ, tests[index].lit, tok.Type, tok.Literal)
+		}
+		index++
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := "let x = 0 017 09 1_000 0b1_0 0o7 0x_fF 0.5 1e3 2.5E-3 0x1p4 0x1.8p1"
+
+	tests := []struct {
+		typ token.Type
+		lit string
+	}{
+		{token.Let, "let"},
+		{token.Identifier, "x"},
+		{token.Assign, "="},
+		{token.Int, "0"},
+		{token.Int, "017"},
+		{token.Int, "09"}, // invalid octal literal, reported by the parser
+		{token.Int, "1_000"},
+		{token.Int, "0b1_0"},
+		{token.Int, "0o7"},
+		{token.Int, "0x_fF"},
+		{token.Float, "0.5"},
+		{token.Float, "1e3"},
+		{token.Float, "2.5E-3"},
+		{token.Float, "0x1p4"},
+		{token.Float, "0x1.8p1"},
+		{token.Semicolon, ""},
+		{token.Eof, ""},
+	}
+
+	file := token.NewFileSet().AddFile("test.mash", input)
+
+	index := 0
+	for tok := range lexer.Lex(file, nil) {
+		if tok.Type != tests[index].typ || tok.Literal != tests[index].lit {
+			t.Fatalf("case %v: expected %s %q, got %s %q", index, tests[index].typ, tests[index].lit, tok.Type, tok.Literal)
+		}
+		index++
+	}
 }
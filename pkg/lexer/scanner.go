@@ -0,0 +1,65 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexer
+
+import "laptudirm.com/x/mash/pkg/token"
+
+// Scanner lexes a mash source synchronously, without a goroutine. The
+// source is lexed incrementally, one top-level statement or command at a
+// time, as it's tokens are requested.
+type Scanner struct {
+	l    *lexer
+	head int  // index of the next token in the lexer's buffer
+	done bool // the whole source has been lexed
+}
+
+// NewScanner creates a new Scanner for the source file, which uses err to
+// handle any lexer errors.
+func NewScanner(file *token.File, err ErrorHandler) *Scanner {
+	return &Scanner{
+		l: newLexer(file, err),
+	}
+}
+
+// Next returns the next token from the source. After the end of the
+// source, it returns EOF tokens.
+func (s *Scanner) Next() token.Token {
+	l := s.l
+
+	if s.head == len(l.tokens) {
+		// reuse the drained token buffer
+		l.tokens = l.tokens[:0]
+		s.head = 0
+
+		for len(l.tokens) == 0 && !s.done {
+			s.done = l.lexBlockItem(eof, token.Eof)
+		}
+
+		if len(l.tokens) == 0 {
+			return token.Token{
+				Type: token.Eof,
+				Pos:  l.file.Pos(l.offset),
+			}
+		}
+	}
+
+	tok := l.tokens[s.head]
+	s.head++
+	return tok
+}
+
+// ErrorCount returns the number of errors encountered by the scanner.
+func (s *Scanner) ErrorCount() int {
+	return s.l.ErrCount
+}
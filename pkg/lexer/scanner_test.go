@@ -0,0 +1,110 @@
+package lexer_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"laptudirm.com/x/mash/pkg/lexer"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+const script = `# a sample script
+let x = obj["a": [1, 2.5], "b": 'x is {x + 1}']
+func f(a, rest...) {
+	for a < 10 {
+		let a += 1
+		if a % 2 == 0 { continue }
+	}
+	return a
+}
+echo "a b" 'c{x}' 2>&1 >>log | cat <<-EOF && true
+	body {x}
+	EOF
+`
+
+func TestScanner(t *testing.T) {
+	for _, src := range []string{script, "", "let x = [1,", "if true {\n\techo 'a{"} {
+		var want, got []token.Token
+		var wantErrs, gotErrs []token.Position
+
+		file := token.NewFileSet().AddFile("test.mash", src)
+
+		for tok := range lexer.Lex(file, func(p token.Position, _ error) { wantErrs = append(wantErrs, p) }) {
+			want = append(want, tok)
+		}
+
+		s := lexer.NewScanner(file, func(p token.Position, _ error) { gotErrs = append(gotErrs, p) })
+		for {
+			tok := s.Next()
+			got = append(got, tok)
+
+			if tok.Type == token.Eof {
+				break
+			}
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: scanner tokens differ from Lex:\nreceived %v\nexpected %v", src, got, want)
+		}
+
+		if !reflect.DeepEqual(gotErrs, wantErrs) || s.ErrorCount() != len(wantErrs) {
+			t.Errorf("%q: scanner errors %v differ from Lex errors %v", src, gotErrs, wantErrs)
+		}
+
+		// scanner keeps returning EOF after the end
+		if tok := s.Next(); tok != got[len(got)-1] {
+			t.Errorf("%q: expected %v after the end, received %v", src, got[len(got)-1], tok)
+		}
+	}
+}
+
+var bigScript = strings.Repeat(script, 1000)
+
+var bigFile = token.NewFileSet().AddFile("big.mash", bigScript)
+
+func BenchmarkLex(b *testing.B) {
+	b.SetBytes(int64(len(bigScript)))
+	for i := 0; i < b.N; i++ {
+		for range lexer.Lex(bigFile, nil) {
+		}
+	}
+}
+
+func BenchmarkScanner(b *testing.B) {
+	b.SetBytes(int64(len(bigScript)))
+	for i := 0; i < b.N; i++ {
+		s := lexer.NewScanner(bigFile, nil)
+		for s.Next().Type != token.Eof {
+		}
+	}
+}
+
+func TestLexContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tokens := lexer.LexContext(ctx, bigFile, nil)
+
+	<-tokens
+	cancel()
+
+	// stream is closed without reading till the end
+	done := make(chan int)
+	go func() {
+		n := 0
+		for range tokens {
+			n++
+		}
+		done <- n
+	}()
+
+	select {
+	case n := <-done:
+		if n > 1 {
+			t.Errorf("expected at most 1 token after cancellation, received %d", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("token stream not closed after cancellation")
+	}
+}
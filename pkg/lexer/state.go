@@ -16,6 +16,7 @@ package lexer
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"unicode"
 
 	"laptudirm.com/x/mash/pkg/token"
@@ -23,60 +24,64 @@ import (
 
 var ErrEOF = errors.New("unexpected EOF")
 
-// run starts lexing the source in l and closes the lexer's token channel
-// when it is done.
-func (l *lexer) run() {
-	l.lexBlock(eof, token.Eof)
-	close(l.Tokens)
+func (l *lexer) lexBlock(eob rune, tok token.Type) {
+	for !l.lexBlockItem(eob, tok) {
+	}
 }
 
-func (l *lexer) lexBlock(eob rune, tok token.Type) {
-	for {
-		r := l.peek()
-		switch {
-		case r == eob:
-			l.consume()
-			l.emit(tok)
-			return // block lexed
+// lexBlockItem lexes the next item of a block which ends with the rune
+// eob, like a statement, a command, or a comment. The end of the block is
+// emitted as a token of type tok. It reports whether the block has ended.
+func (l *lexer) lexBlockItem(eob rune, tok token.Type) bool {
+	r := l.peek()
+	switch {
+	case r == eob:
+		l.consume()
+		l.emit(tok)
+		return true // block lexed
 
-		// ignore all space runes
-		case unicode.IsSpace(r):
-			l.consumeAllSpace()
+	case r == eof:
+		// unterminated block, reported by the parser
+		return true
 
-		case r == '#':
-			l.lexComment()
+	// ignore all space runes
+	case unicode.IsSpace(r):
+		l.consumeAllSpace()
 
-		// command or statement
-		default:
-			if isAlphabet(r) {
-				l.consumeWord()
-
-				word := l.literal()
-				// statement starts with keyword
-				if token.IsKeyword(word) {
-					t := token.Lookup(word)
-					l.emit(t)
-					l.insertSemi = t.InsertSemi()
-
-					l.lexStmt(eob)
-
-					// semicolon insertion
-					l.emit(token.Semicolon)
-					break
-				}
-
-				// commands don't start with a keyword
-				// TODO: cleanup
-				l.rdOffset = l.offset
-				l.pos = l.start
-			}
+	case r == '#':
+		l.lexComment()
 
-			l.lexCmd(eob)
+	// command or statement
+	default:
+		if isAlphabet(r) {
+			l.consumeWord(wordEnd(eob))
+
+			word := l.literal()
+			// statement starts with keyword
+			if token.IsKeyword(word) {
+				t := token.Lookup(word)
+				l.emit(t)
+				l.insertSemi = t.InsertSemi()
+
+				l.lexStmt(eob)
+
+				// semicolon insertion
+				l.emit(token.Semicolon)
+				break
+			}
 
-			// semicolon insertion
-			l.emit(token.Semicolon)
+			// commands don't start with a keyword
+			// TODO: cleanup
+			l.rdOffset = l.offset
 		}
+
+		l.lexCmd(eob)
+
+		// semicolon insertion
+		l.emit(token.Semicolon)
 	}
+
+	return false
 }
 
 func isAlphabet(r rune) bool {
@@ -84,11 +89,14 @@ func isAlphabet(r rune) bool {
 }
 
 func (l *lexer) lexStmt(eos rune) {
+	// statements inside a subshell end at an unmatched ')'
+	var parens int
+
 	for {
 		l.consume()
 
 		switch {
-		case l.ch == eos:
+		case l.ch == eos && (eos != ')' || parens == 0), l.ch == eof:
 			l.backup()
 			return // will be handled by caller
 
@@ -132,11 +140,23 @@ func (l *lexer) lexStmt(eos rune) {
 			// semicolon should be inserted after a string
 			l.insertSemi = true
 
+		case l.ch == '$' && l.atSubst():
+			l.lexSubst()
+			// semicolon should be inserted after a substitution
+			l.insertSemi = true
+
 		// all operator starting runes are themselves operators
 		case token.IsOperator(string(l.ch)):
 			t := l.lexStmtOp()
 			l.insertSemi = t.InsertSemi()
 
+			switch t {
+			case token.LeftParen:
+				parens++
+			case token.RightParen:
+				parens--
+			}
+
 		case l.ch == '#':
 			// line comment
 			l.lexComment()
@@ -163,30 +183,47 @@ func isIdentStart(r rune) bool {
 	return r == '_' || unicode.IsLetter(r)
 }
 
+// lexNum lexes an integer or floating point number literal, whose first
+// digit has already been consumed. Decimal and hexadecimal literals with a
+// fraction or an exponent are floating point literals.
 func (l *lexer) lexNum() {
 	base := 10 // number base
 
 	// 0b, 0o, or 0x base specs
-	var ok bool
 	if l.ch == '0' {
-		if base, ok = baseOf(l.peek()); ok {
+		if b, ok := baseOf(l.peek()); ok {
+			base = b
 			l.consume()
+
+			if l.peek() == '_' {
+				l.consume()
+			}
+
+			l.lexDigits(base, true)
 		}
+	}
 
+	if base == 10 {
+		// rest of the integer part, which may have a leading 0, and may
+		// be separated from the first digit by an underscore
 		if l.peek() == '_' {
 			l.consume()
+			l.lexDigits(base, true)
+		} else {
+			l.lexDigits(base, false)
 		}
 	}
 
-	l.lexDigits(base, ok)
-
-	if base <= 8 {
-		goto tokenize
+	typ := token.Int
+	if base == 2 || base == 8 {
+		l.emit(typ)
+		return
 	}
 
 	if l.peek() == '.' {
 		l.consume()
 		l.lexDigits(base, true)
+		typ = token.Float
 	}
 
 	if isExponent(l.peek(), base) {
@@ -198,10 +235,10 @@ func (l *lexer) lexNum() {
 		}
 
 		l.lexDigits(10, true)
+		typ = token.Float
 	}
 
-tokenize:
-	l.emit(token.Number)
+	l.emit(typ)
 }
 
 func baseOf(r rune) (int, bool) {
@@ -213,7 +250,7 @@ func baseOf(r rune) (int, bool) {
 	case 'x', 'X':
 		return 16, true
 	default:
-		return 8, false
+		return 10, false
 	}
 }
 
@@ -342,6 +379,10 @@ func (l *lexer) lexStmtOp() token.Type {
 		t = token.Comma
 	case '.':
 		t = token.Period
+		if l.peek() == '.' {
+			l.consume()
+			t = l.makeOp('.', token.Ellipsis, token.Illegal)
+		}
 	case ')':
 		t = token.RightParen
 	case ']':
@@ -357,33 +398,90 @@ func (l *lexer) lexStmtOp() token.Type {
 }
 
 func (l *lexer) lexCmd(eoc rune) {
+	// commands are continued on the next line after a trailing
+	// pipe or logical operator
+	var continued bool
+
+	// subshells can only be at the start of a command
+	start := true
+
 	for {
 		l.consume()
 
 		switch {
-		case l.ch == eoc:
+		case l.ch == eoc, l.ch == eof:
+			if l.ch == eof {
+				// report missing heredoc bodies
+				l.lexHeredocBodies()
+			}
+
 			l.backup()
 			return // will be handled by lexBlock
 
 		case l.ch == '\n':
+			// heredoc bodies start after the end of the line
+			l.lexHeredocBodies()
+
+			if continued {
+				l.consumeAllSpace()
+				break
+			}
+
 			return // insertion in handled by lexBlock
 
+		case l.ch == ';':
+			return // emitted by lexBlock
+
 		case unicode.IsSpace(l.ch):
 			// ignore all space
 			l.consumeSpace()
 
+		case l.ch == '(' && start:
+			l.emit(token.LeftParen)
+			l.lexBlock(')', token.RightParen)
+			continued, start = false, false
+
 		case l.ch == '"' || l.ch == '\'' || l.ch == '`':
 			l.lexString()
+			continued, start = false, false
+
+		case l.ch == '$' && l.atSubst():
+			l.lexSubst()
+			continued, start = false, false
+
+		case l.ch == '$' && isEnvStart(l.peek()):
+			l.lexEnvVar()
+			continued, start = false, false
 
 		case l.ch == '#':
 			l.lexComment()
 
 		case isCmdOp(l.ch):
-			l.lexCmdOp()
+			switch l.lexCmdOp() {
+			case token.LogicalAnd, token.LogicalOr, token.Or:
+				continued, start = true, true
+			case token.Not:
+				continued, start = false, true
+			default:
+				continued, start = false, false
+			}
+
+		case isRedirect(l.ch):
+			l.lexRedirect()
+			continued, start = false, false
 
 		default:
-			l.consumeWord()
-			l.emit(token.String)
+			l.consumeWord(wordEnd(eoc))
+
+			// word is the file descriptor of a redirection
+			if isRedirect(l.peek()) && isDecimal(l.literal()) {
+				l.consume()
+				l.lexRedirect()
+			} else {
+				l.emit(token.String)
+			}
+
+			continued, start = false, false
 		}
 	}
 }
@@ -404,6 +502,9 @@ func (l *lexer) lexCmdOp() token.Type {
 		t = l.makeOp('|', token.LogicalOr, token.Or)
 	case '&':
 		t = l.makeOp('&', token.LogicalAnd, token.And)
+		if t == token.And {
+			t = l.makeOp('>', token.RedirectAll, token.And)
+		}
 	case '!':
 		t = token.Not
 	default:
@@ -415,6 +516,189 @@ func (l *lexer) lexCmdOp() token.Type {
 	return t
 }
 
+func isRedirect(r rune) bool {
+	return r == '<' || r == '>'
+}
+
+// isDecimal reports whether s is a non-empty string of decimal digits.
+func isDecimal(s string) bool {
+	for _, r := range s {
+		if !isBaseDigit(r, 10) {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+// lexRedirect lexes a redirection operator starting with the current rune.
+// The literal of the token includes any file descriptor before it.
+func (l *lexer) lexRedirect() token.Type {
+	var t token.Type
+	switch l.ch {
+	case '<':
+		t = l.makeOp('<', token.ShiftLeft, token.RedirectIn)
+
+		if t == token.ShiftLeft {
+			t = l.makeOp('<', token.HereString, token.Heredoc)
+		}
+
+		if t == token.Heredoc {
+			return l.lexHeredoc()
+		}
+	case '>':
+		t = l.makeOp('>', token.RedirectAppend, token.RedirectOut)
+
+		if t == token.RedirectOut {
+			t = l.makeOp('&', token.RedirectDup, token.RedirectOut)
+		}
+	default:
+		// unreachable
+		t = token.Illegal
+	}
+
+	l.emit(t)
+	return t
+}
+
+// heredoc represents a heredoc operator whose body is yet to be lexed.
+type heredoc struct {
+	delim       string // terminator of the body
+	indent      bool   // leading tabs are ignored
+	interpolate bool   // body contains embedded expressions
+}
+
+var ErrHeredoc = errors.New("invalid heredoc delimiter")
+
+// lexHeredoc lexes the rest of a heredoc operator after the <<, and adds
+// the heredoc to the lexer's list of heredocs with pending bodies.
+func (l *lexer) lexHeredoc() token.Type {
+	var h heredoc
+
+	if l.peek() == '-' {
+		l.consume()
+		h.indent = true
+	}
+
+	// quoted delimiters specify template heredocs
+	if l.peek() == '\'' {
+		l.consume()
+		h.interpolate = true
+	}
+
+	start := l.rdOffset
+	for isIdent(l.peek()) {
+		l.consume()
+	}
+
+	h.delim = l.src[start:l.rdOffset]
+
+	if h.interpolate && l.peek() == '\'' {
+		l.consume()
+	} else if h.interpolate {
+		h.delim = ""
+	}
+
+	if h.delim == "" {
+		l.error(ErrHeredoc)
+		l.emit(token.Illegal)
+		return token.Illegal
+	}
+
+	l.heredocs = append(l.heredocs, h)
+	l.emit(token.Heredoc)
+	return token.Heredoc
+}
+
+// lexHeredocBodies lexes the bodies of the pending heredocs, which start
+// on the line after the one which contains their operators.
+func (l *lexer) lexHeredocBodies() {
+	if len(l.heredocs) == 0 {
+		return
+	}
+
+	l.ignore()
+
+	for _, h := range l.heredocs {
+		if !l.lexHeredocBody(h) {
+			break
+		}
+	}
+
+	l.heredocs = nil
+}
+
+// lexHeredocBody lexes the body of the heredoc h, till a line consisting
+// only of it's delimiter. It returns false if the body is unterminated.
+func (l *lexer) lexHeredocBody(h heredoc) bool {
+	for {
+		// check for terminator at line start
+		line := l.src[l.rdOffset:]
+		if i := strings.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+
+		if h.indent {
+			line = strings.TrimLeft(line, "\t")
+		}
+
+		if line == h.delim {
+			l.emit(token.HeredocBody)
+
+			// consume terminator line
+			for r := l.peek(); r != '\n' && r != eof; r = l.peek() {
+				l.consume()
+			}
+
+			l.emit(token.HeredocEnd)
+
+			if l.peek() == '\n' {
+				l.consume()
+				l.ignore()
+			}
+
+			return true
+		}
+
+		if l.atEnd() {
+			l.error(ErrEOF)
+			l.emit(token.Illegal)
+			return false
+		}
+
+		// consume line, including the newline
+		for r := l.peek(); r != eof; r = l.peek() {
+			l.consume()
+
+			if r == '\n' {
+				break
+			}
+
+			if !h.interpolate {
+				continue
+			}
+
+			switch {
+			// escaped "{"
+			case r == '\\' && l.peek() == '{':
+				l.consume()
+
+			// embedded expression
+			case r == '{':
+				// emit body till the "{"
+				l.backup()
+				l.emit(token.HeredocBody)
+
+				l.consume()
+				l.emit(token.LeftBrace)
+
+				l.lexEmbeddedExpr()
+				l.emit(token.RightBrace) // ending "}"
+			}
+		}
+	}
+}
+
 func (l *lexer) lexComment() {
 	// consume tokens till newline or eof
 	for r := l.peek(); r != '\n' && r != eof; r = l.peek() {
@@ -524,6 +808,11 @@ func (l *lexer) lexEmbeddedExpr() {
 		case l.ch == '}':
 			return
 
+		case l.ch == eof:
+			// unterminated template, reported by caller
+			l.backup()
+			return
+
 		case unicode.IsSpace(l.ch):
 			l.consumeAllSpace()
 
@@ -536,6 +825,9 @@ func (l *lexer) lexEmbeddedExpr() {
 		case l.ch == '"' || l.ch == '\'' || l.ch == '`':
 			l.lexString()
 
+		case l.ch == '$' && l.atSubst():
+			l.lexSubst()
+
 		// all operator starting runes are themselves operators
 		case token.IsOperator(string(l.ch)):
 			l.lexStmtOp()
@@ -567,7 +859,8 @@ func (l *lexer) lexStringEscape(t rune) {
 	case 'U':
 		radix, n = 16, 8
 	default:
-		if t == '\'' && l.peek() == '{' {
+		// escaped "{" in templates, and "$" in interpreted strings
+		if t == '\'' && l.peek() == '{' || t == '"' && l.peek() == '$' {
 			l.consume()
 			return
 		}
@@ -617,8 +910,123 @@ func isIdent(r rune) bool {
 	return isIdentStart(r) || unicode.IsDigit(r)
 }
 
-func (l *lexer) consumeWord() {
-	for r := l.peek(); !unicode.IsSpace(r) && r != eof; r = l.peek() {
+// wordEnd returns the rune which ends the words of a command inside a
+// block, subshell, or substitution which ends with the rune eob. Braces
+// don't end words since they are used in brace expansions.
+func wordEnd(eob rune) rune {
+	if eob == ')' {
+		return eob
+	}
+
+	return eof
+}
+
+// consumeWord consumes all runes till a space rune, a semicolon, the start
+// of a redirection operator, the start of an environment variable or
+// command substitution, or the rune end. An end of eof doesn't end the word
+// before the end of the source. Runes escaped with a '\\' never end the
+// word, and the escapes are removed during expansion.
+func (l *lexer) consumeWord(end rune) {
+	escaped := l.ch == '\\'
+	for r := l.peek(); r != eof && (escaped || !unicode.IsSpace(r) && r != ';' && !isRedirect(r) && r != end && !l.atEnvVar() && !l.atNextSubst()); r = l.peek() {
+		l.consume()
+		escaped = !escaped && r == '\\'
+	}
+}
+
+// atSubst reports whether the current '$' rune starts a command
+// substitution, $(command) or $?(command).
+func (l *lexer) atSubst() bool {
+	return isSubst(l.src[l.rdOffset:])
+}
+
+// atNextSubst reports whether the next rune starts a command substitution.
+func (l *lexer) atNextSubst() bool {
+	i := l.rdOffset
+	return i < len(l.src) && l.src[i] == '$' && isSubst(l.src[i+1:])
+}
+
+// isSubst reports whether s, which follows a '$', continues the start of a
+// command substitution.
+func isSubst(s string) bool {
+	return strings.HasPrefix(s, "(") || strings.HasPrefix(s, "?(")
+}
+
+// lexSubst lexes a command substitution starting with the current '$'
+// rune. The command is lexed till the closing paren of the substitution.
+func (l *lexer) lexSubst() {
+	t := token.Subst
+	if l.peek() == '?' {
+		l.consume()
+		t = token.SubstResult
+	}
+
+	l.consume() // '('
+	l.emit(t)
+
+	l.lexCmd(')')
+	if l.peek() != ')' {
+		// unterminated substitution, reported by the parser
+		return
+	}
+
+	l.consume()
+	l.emit(token.RightParen)
+}
+
+// ErrEnvVar is reported for malformed ${NAME} environment variables.
+var ErrEnvVar = errors.New("invalid ${} environment variable")
+
+// lexEnvVar lexes an environment variable starting with the current '$'
+// rune, in the form $NAME, ${NAME}, or ${NAME?}.
+func (l *lexer) lexEnvVar() {
+	braced := l.peek() == '{'
+	if braced {
 		l.consume()
+
+		if !isEnvName(l.peek()) {
+			l.error(ErrEnvVar)
+			l.emit(token.Illegal)
+			return
+		}
 	}
+
+	for isEnvName(l.peek()) || isBaseDigit(l.peek(), 10) {
+		l.consume()
+	}
+
+	if braced {
+		// variable which must be set
+		if l.peek() == '?' {
+			l.consume()
+		}
+
+		if l.peek() != '}' {
+			l.error(ErrEnvVar)
+			l.emit(token.Illegal)
+			return
+		}
+
+		l.consume()
+	}
+
+	l.emit(token.EnvVar)
+}
+
+// atEnvVar reports whether the next rune starts an environment variable.
+func (l *lexer) atEnvVar() bool {
+	i := l.rdOffset
+	return i+1 < len(l.src) && l.src[i] == '$' && (l.src[i+1] == '{' || isEnvStart(rune(l.src[i+1])))
+}
+
+// isEnvStart reports whether r can follow the '$' of an environment
+// variable.
+func isEnvStart(r rune) bool {
+	return r == '{' || isEnvName(r)
+}
+
+// isEnvName reports whether r can start the name of an environment
+// variable.
+func isEnvName(r rune) bool {
+	return r == '_' || isAlphabet(r)
 }
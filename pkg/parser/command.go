@@ -15,6 +15,8 @@ package parser
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"laptudirm.com/x/mash/pkg/ast"
 	"laptudirm.com/x/mash/pkg/token"
@@ -110,41 +112,739 @@ func (p *parser) parsePipeCommand() (ast.Command, error) {
 	return expr, nil
 }
 
-// PrimaryCommand = command_arg { command_arg } .
+// wordTokens is the list of tokens which can be a part of a command
+// argument.
+var wordTokens = []token.Type{
+	token.String,
+	token.Template,
+	token.EnvVar,
+	token.Subst,
+	token.SubstResult,
+}
+
+// redirectOps is the list of redirection operators.
+var redirectOps = []token.Type{
+	token.RedirectIn,
+	token.RedirectOut,
+	token.RedirectAppend,
+	token.RedirectAll,
+	token.RedirectDup,
+	token.HereString,
+	token.Heredoc,
+}
+
+// PrimaryCommand = SubshellCommand | CommandComponent { CommandComponent } .
 func (p *parser) parsePrimaryCommand() (ast.Command, error) {
-	if !p.check(token.String, token.Template) {
+	// bodies of heredocs in a previous line of the command
+	if err := p.parseHeredocBodies(); err != nil {
+		return nil, err
+	}
+
+	if p.check(token.LeftParen) {
+		return p.parseSubshellCommand()
+	}
+
+	if !p.check(wordTokens...) && !p.check(redirectOps...) {
 		return nil, fmt.Errorf("unexpected token %s", p.pTok)
 	}
 
 	var components []ast.CommandComponent
 
-componentLoop:
+	for p.check(wordTokens...) || p.check(redirectOps...) {
+		component, err := p.parseCommandComponent()
+		if err != nil {
+			return nil, err
+		}
+
+		components = append(components, component)
+	}
+
+	return &ast.LiteralCommand{
+		Components: components,
+	}, nil
+}
+
+// SubshellCommand = "(" StatementList ")" { Redirect | Heredoc } .
+func (p *parser) parseSubshellCommand() (*ast.SubshellCommand, error) {
+	p.next()
+	opening := p.current()
+
+	statements := p.parseStatementList(token.RightParen)
+	if !p.match(token.RightParen) {
+		return nil, fmt.Errorf("expected ')', received %s", p.pTok)
+	}
+
+	cmd := &ast.SubshellCommand{
+		Opening:    opening,
+		Statements: statements,
+		Closing:    p.current(),
+	}
+
+	for p.check(redirectOps...) {
+		r, err := p.parseCommandComponent()
+		if err != nil {
+			return nil, err
+		}
+
+		cmd.Redirects = append(cmd.Redirects, r)
+	}
+
+	return cmd, nil
+}
+
+// CommandComponent = CommandArg | Redirect | Heredoc .
+func (p *parser) parseCommandComponent() (ast.CommandComponent, error) {
+	if p.check(token.Heredoc) {
+		return p.parseHeredoc()
+	}
+
+	if p.check(redirectOps...) {
+		return p.parseRedirect()
+	}
+
+	return p.parseCommandArg()
+}
+
+// CommandArg = WordPart { WordPart } .
+//
+// The parts of a command argument are not separated by spaces.
+func (p *parser) parseCommandArg() (ast.CommandComponent, error) {
+	var parts []ast.CommandComponent
+
 	for {
-		var component ast.CommandComponent
+		part, err := p.parseWordPart()
+		if err != nil {
+			return nil, err
+		}
+
+		parts = append(parts, part...)
+
+		// next part must start right after the previous one
+		if !p.check(wordTokens...) || p.pPos != parts[len(parts)-1].End() {
+			break
+		}
+	}
+
+	parts = parseExpansions(parts)
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+
+	return &ast.Word{
+		Parts: parts,
+	}, nil
+}
+
+// wordItem is a single byte of an unquoted string part of a command
+// argument, or one of the argument's other parts.
+type wordItem struct {
+	part ast.CommandComponent // part which isn't an unquoted string
+	lit  *ast.StringLiteral   // unquoted string containing the byte
+	off  int                  // offset of the byte in lit
+}
+
+// is reports whether item is the unquoted byte b.
+func (item wordItem) is(b byte) bool {
+	return item.part == nil && item.lit.Value[item.off] == b
+}
+
+// pos returns the position of the unquoted byte item.
+func (item wordItem) pos() token.Pos {
+	return item.lit.Token.Pos + token.Pos(item.off)
+}
+
+// token returns the unquoted byte item as a token of type t.
+func (item wordItem) token(t token.Type) token.Token {
+	return token.Token{
+		Type:    t,
+		Literal: item.lit.Value[item.off : item.off+1],
+		Pos:     item.pos(),
+	}
+}
+
+// parseExpansions parses the tilde and brace expansions in the unquoted
+// string parts of a command argument, and returns the new parts of the
+// argument. Braces which don't form a valid expansion are left as is.
+func parseExpansions(parts []ast.CommandComponent) []ast.CommandComponent {
+	var items []wordItem
+	for _, part := range parts {
+		lit, ok := part.(*ast.StringLiteral)
+		if !ok || lit.Quoted {
+			items = append(items, wordItem{part: part})
+			continue
+		}
+
+		for i := range lit.Value {
+			items = append(items, wordItem{lit: lit, off: i})
+		}
+	}
 
-		switch p.pTok {
-		case token.String:
-			p.next()
+	// tilde expansions are only valid at the start of an argument
+	var expanded []ast.CommandComponent
+	if tilde, n := parseTilde(items); tilde != nil {
+		expanded = append(expanded, tilde)
+		items = items[n:]
+	}
+
+	rest, _ := parseWordItems(items, 0, false)
+	return append(expanded, rest...)
+}
+
+// parseTilde parses the ~ or ~user at the start of items, which must be
+// followed by a '/' or the end of the argument. It returns the expansion
+// and the number of items it spans, or nil if items don't start with one.
+func parseTilde(items []wordItem) (*ast.TildeExpansion, int) {
+	if len(items) == 0 || !items[0].is('~') {
+		return nil, 0
+	}
+
+	lit, n := items[0].lit, 1
+	for ; n < len(items) && items[n].part == nil && items[n].lit == lit; n++ {
+		if c := lit.Value[items[n].off]; c == '/' {
+			break
+		} else if !isUserName(c) {
+			return nil, 0
+		}
+	}
+
+	if n < len(items) && !items[n].is('/') {
+		// user name is followed by other parts
+		return nil, 0
+	}
+
+	start := items[0].off
+	return &ast.TildeExpansion{
+		Token: token.Token{
+			Type:    token.String,
+			Literal: lit.Value[start : start+n],
+			Pos:     items[0].pos(),
+		},
+		User: lit.Value[start+1 : start+n],
+	}, n
+}
+
+// isUserName reports whether b can be a part of a user name.
+func isUserName(b byte) bool {
+	return isEnvName(b) || '0' <= b && b <= '9' || b == '.' || b == '-'
+}
+
+// parseWordItems parses the items starting at i into the parts of a command
+// argument, till the end of the items or, if nested inside the element of
+// a brace expansion, an unquoted ',' or '}'. It returns the parts and the
+// index of the item at which it stopped.
+func parseWordItems(items []wordItem, i int, nested bool) ([]ast.CommandComponent, int) {
+	var parts []ast.CommandComponent
+
+	start := -1 // start of the current unquoted string
+	flush := func(end int) {
+		if start >= 0 {
+			parts = append(parts, unquotedString(items[start:end]))
+			start = -1
+		}
+	}
+
+	for i < len(items) {
+		item := items[i]
+		switch {
+		case item.part != nil:
+			flush(i)
+			parts = append(parts, item.part)
+			i++
+			continue
+		case nested && (item.is(',') || item.is('}')):
+			flush(i)
+			return parts, i
+		case item.is('{'):
+			if brace, next := parseBrace(items, i); brace != nil {
+				flush(i)
+				parts = append(parts, brace)
+				i = next
+				continue
+			}
+		}
+
+		if start < 0 {
+			start = i
+		}
+
+		i++
+	}
+
+	flush(i)
+	return parts, i
+}
+
+// unquotedString returns the unquoted string made up of the bytes items,
+// which are adjacent bytes of a single string part.
+func unquotedString(items []wordItem) *ast.StringLiteral {
+	lit := items[0].lit
+	val := lit.Value[items[0].off : items[len(items)-1].off+1]
+
+	return &ast.StringLiteral{
+		Token: token.Token{
+			Type:    token.String,
+			Literal: val,
+			Pos:     items[0].pos(),
+		},
+		Value: val,
+	}
+}
+
+// parseBrace parses the brace expansion starting at the '{' item i. It
+// returns the expansion and the index of the item after it, or nil if the
+// braces don't form a valid expansion.
+func parseBrace(items []wordItem, i int) (ast.CommandComponent, int) {
+	if r, next := parseBraceRange(items, i); r != nil {
+		return r, next
+	}
+
+	var elements []ast.CommandComponent
+	for j := i + 1; ; {
+		parts, k := parseWordItems(items, j, true)
+		if k >= len(items) {
+			// unterminated brace
+			return nil, 0
+		}
 
-			component = &ast.StringLiteral{
-				Token: p.current(),
-				Value: p.lit,
+		switch len(parts) {
+		case 0:
+			elements = append(elements, &ast.StringLiteral{
+				Token: token.Token{Type: token.String, Pos: items[k].pos()},
+			})
+		case 1:
+			elements = append(elements, parts[0])
+		default:
+			elements = append(elements, &ast.Word{Parts: parts})
+		}
+
+		if items[k].is('}') {
+			// brace expansions need at least one ','
+			if len(elements) < 2 {
+				return nil, 0
 			}
-		case token.Template:
-			template, err := p.parseTemplateLit()
+
+			return &ast.BraceExpansion{
+				Lbrace:   items[i].token(token.LeftBrace),
+				Elements: elements,
+				Rbrace:   items[k].token(token.RightBrace),
+			}, k + 1
+		}
+
+		j = k + 1
+	}
+}
+
+// parseBraceRange parses the brace range starting at the '{' item i, of
+// the form {x..y} or {x..y..incr}, where the bounds are both integers or
+// both single letters. It returns the range and the index of the item after
+// it, or nil if the braces don't form a valid range.
+func parseBraceRange(items []wordItem, i int) (*ast.BraceRange, int) {
+	lit, off := items[i].lit, items[i].off
+
+	n := strings.IndexByte(lit.Value[off:], '}')
+	if n < 0 {
+		return nil, 0
+	}
+
+	bounds := strings.Split(lit.Value[off+1:off+n], "..")
+	if len(bounds) != 2 && len(bounds) != 3 {
+		return nil, 0
+	}
+
+	r := &ast.BraceRange{
+		Token: token.Token{
+			Type:    token.String,
+			Literal: lit.Value[off : off+n+1],
+			Pos:     items[i].pos(),
+		},
+		Incr: 1,
+	}
+
+	if len(bounds) == 3 {
+		incr, err := strconv.Atoi(bounds[2])
+		if err != nil {
+			return nil, 0
+		}
+
+		if incr < 0 {
+			incr = -incr
+		}
+
+		if incr != 0 {
+			r.Incr = incr
+		}
+	}
+
+	from, to := bounds[0], bounds[1]
+	if isLetter(from) && isLetter(to) {
+		r.From, r.To, r.Chars = int(from[0]), int(to[0]), true
+		return r, i + n + 1
+	}
+
+	var err1, err2 error
+	r.From, err1 = strconv.Atoi(from)
+	r.To, err2 = strconv.Atoi(to)
+	if err1 != nil || err2 != nil {
+		return nil, 0
+	}
+
+	// pad the integers if any bound has leading zeros
+	if zeroPadded(from) || zeroPadded(to) {
+		r.Width = len(from)
+		if len(to) > r.Width {
+			r.Width = len(to)
+		}
+	}
+
+	return r, i + n + 1
+}
+
+// isLetter reports whether s is a single ascii letter.
+func isLetter(s string) bool {
+	return len(s) == 1 && ('a' <= s[0] && s[0] <= 'z' || 'A' <= s[0] && s[0] <= 'Z')
+}
+
+// zeroPadded reports whether the integer s has leading zeros.
+func zeroPadded(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	return len(s) > 1 && s[0] == '0'
+}
+
+// WordPart = string | env_var | TemplateLit | Subst .
+func (p *parser) parseWordPart() ([]ast.CommandComponent, error) {
+	switch p.pTok {
+	case token.String:
+		p.next()
+
+		switch p.lit[0] {
+		case '"':
+			// environment variables are expanded inside
+			return p.parseInterpretedString(p.current())
+		case '`':
+			val, err := strconv.Unquote(p.lit)
 			if err != nil {
 				return nil, err
 			}
 
-			component = template
-		default:
-			break componentLoop
+			return []ast.CommandComponent{&ast.StringLiteral{
+				Token:  p.current(),
+				Value:  val,
+				Quoted: true,
+			}}, nil
 		}
 
-		components = append(components, component)
+		return []ast.CommandComponent{&ast.StringLiteral{
+			Token: p.current(),
+			Value: p.lit,
+		}}, nil
+	case token.EnvVar:
+		p.next()
+
+		env, err := parseEnvExpansion(p.current())
+		if err != nil {
+			return nil, err
+		}
+
+		return []ast.CommandComponent{env}, nil
+	case token.Template:
+		tmpl, err := p.parseTemplateLit()
+		if err != nil {
+			return nil, err
+		}
+
+		return []ast.CommandComponent{tmpl}, nil
+	case token.Subst, token.SubstResult:
+		subst, err := p.parseSubst()
+		if err != nil {
+			return nil, err
+		}
+
+		return []ast.CommandComponent{subst}, nil
+	default:
+		return nil, fmt.Errorf("expected command argument, received %s", p.pTok)
 	}
+}
 
-	return &ast.LiteralCommand{
-		Components: components,
+// Subst = ( "$(" | "$?(" ) OrCommand ")" .
+func (p *parser) parseSubst() (*ast.CommandSubstitution, error) {
+	p.next()
+	tok := p.current()
+
+	cmd, err := p.parseOrCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.match(token.RightParen) {
+		return nil, fmt.Errorf("expected ')', received %s", p.pTok)
+	}
+
+	return &ast.CommandSubstitution{
+		Token:   tok,
+		Command: cmd,
+		Closing: p.current(),
+	}, nil
+}
+
+// parseInterpretedString parses the "string" tok of a command, splitting
+// it into string literals and the environment variables inside it. The
+// tokens of the literals are the parts of tok between the variables, so
+// the first and last literals include the quotes.
+func (p *parser) parseInterpretedString(tok token.Token) ([]ast.CommandComponent, error) {
+	var parts []ast.CommandComponent
+
+	lit := tok.Literal
+	start := 0 // start of the current string part
+
+	// literal adds the string part which ends at the offset end
+	literal := func(end int) error {
+		part := lit[start:end]
+
+		// strip the quotes and the escapes of $, which are not valid
+		// in go strings
+		val := strings.TrimPrefix(part, `"`)
+		if end == len(lit) {
+			val = strings.TrimSuffix(val, `"`)
+		}
+
+		val, err := unquote(`"` + val + `"`)
+		if err != nil {
+			return err
+		}
+
+		parts = append(parts, &ast.StringLiteral{
+			Token: token.Token{
+				Type:    token.String,
+				Literal: part,
+				Pos:     tok.Pos + token.Pos(start),
+			},
+			Value:  val,
+			Quoted: true,
+		})
+
+		return nil
+	}
+
+	for i := 1; i < len(lit)-1; i++ {
+		switch {
+		case lit[i] == '\\':
+			i++ // skip escaped rune
+		case lit[i] == '$' && isEnvStart(lit[i+1]):
+			n := envLength(lit[i:])
+			if n == 0 {
+				return nil, fmt.Errorf("invalid ${} environment variable")
+			}
+
+			if err := literal(i); err != nil {
+				return nil, err
+			}
+
+			env, err := parseEnvExpansion(token.Token{
+				Type:    token.EnvVar,
+				Literal: lit[i : i+n],
+				Pos:     tok.Pos + token.Pos(i),
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			parts = append(parts, env)
+			start = i + n
+			i += n - 1
+		}
+	}
+
+	if err := literal(len(lit)); err != nil {
+		return nil, err
+	}
+
+	return parts, nil
+}
+
+// parseEnvExpansion parses the environment variable tok, which is of the
+// form $NAME, ${NAME}, or ${NAME?}.
+func parseEnvExpansion(tok token.Token) (*ast.EnvExpansion, error) {
+	name := strings.TrimPrefix(tok.Literal, "$")
+
+	var required bool
+	if strings.HasPrefix(name, "{") {
+		name = strings.TrimSuffix(name[1:], "}")
+
+		required = strings.HasSuffix(name, "?")
+		name = strings.TrimSuffix(name, "?")
+	}
+
+	if envLength("${"+name+"}") == 0 {
+		return nil, fmt.Errorf("invalid environment variable name %q", name)
+	}
+
+	return &ast.EnvExpansion{
+		Token:    tok,
+		Name:     name,
+		Required: required,
 	}, nil
 }
+
+// envLength returns the length of the environment variable at the start
+// of s, or 0 if s doesn't start with a valid environment variable.
+func envLength(s string) int {
+	if len(s) < 2 || s[0] != '$' {
+		return 0
+	}
+
+	braced := s[1] == '{'
+
+	i := 1
+	if braced {
+		i++
+	}
+
+	if i >= len(s) || !isEnvName(s[i]) {
+		return 0
+	}
+
+	for i < len(s) && (isEnvName(s[i]) || '0' <= s[i] && s[i] <= '9') {
+		i++
+	}
+
+	if !braced {
+		return i
+	}
+
+	if i < len(s) && s[i] == '?' {
+		i++
+	}
+
+	if i >= len(s) || s[i] != '}' {
+		return 0
+	}
+
+	return i + 1
+}
+
+// isEnvStart reports whether b can follow the '$' of an environment
+// variable.
+func isEnvStart(b byte) bool {
+	return b == '{' || isEnvName(b)
+}
+
+// isEnvName reports whether b can start the name of an environment
+// variable.
+func isEnvName(b byte) bool {
+	return b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// Redirect = redirect_op CommandArg .
+func (p *parser) parseRedirect() (*ast.Redirect, error) {
+	p.next()
+	op := p.current()
+
+	// input is redirected from standard input by default, and
+	// output is redirected from standard output
+	fd := 1
+	if op.Type == token.RedirectIn || op.Type == token.HereString {
+		fd = 0
+	}
+
+	// explicit file descriptor before the operator
+	if prefix := strings.TrimRight(op.Literal, "<>&"); prefix != "" {
+		var err error
+		if fd, err = strconv.Atoi(prefix); err != nil {
+			return nil, fmt.Errorf("invalid file descriptor %s", prefix)
+		}
+	}
+
+	target, err := p.parseCommandArg()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.Redirect{
+		Operator: op,
+		Fd:       fd,
+		Target:   target,
+	}, nil
+}
+
+// Heredoc = heredoc_op .
+func (p *parser) parseHeredoc() (*ast.Heredoc, error) {
+	p.match(token.Heredoc)
+	op := p.current()
+
+	// operator is of the form [fd]<<[-]['](delimiter)[']
+	lit := op.Literal
+	i := strings.Index(lit, "<<")
+
+	fd := 0
+	if i > 0 {
+		var err error
+		if fd, err = strconv.Atoi(lit[:i]); err != nil {
+			return nil, fmt.Errorf("invalid file descriptor %s", lit[:i])
+		}
+	}
+
+	lit = lit[i+2:]
+
+	indent := strings.HasPrefix(lit, "-")
+	lit = strings.TrimPrefix(lit, "-")
+
+	interpolate := strings.HasPrefix(lit, "'")
+	lit = strings.Trim(lit, "'")
+
+	heredoc := &ast.Heredoc{
+		Operator:    op,
+		Fd:          fd,
+		Delimiter:   lit,
+		Indent:      indent,
+		Interpolate: interpolate,
+	}
+
+	// body is parsed after the end of the line
+	p.heredocs = append(p.heredocs, heredoc)
+	return heredoc, nil
+}
+
+// parseHeredocBodies parses the bodies of the pending heredocs, if they
+// are present.
+func (p *parser) parseHeredocBodies() error {
+	for len(p.heredocs) > 0 && p.check(token.HeredocBody) {
+		if err := p.parseHeredocBody(p.heredocs[0]); err != nil {
+			return err
+		}
+
+		p.heredocs = p.heredocs[1:]
+	}
+
+	return nil
+}
+
+// HeredocBody = heredoc_text { "{" Expression "}" heredoc_text } heredoc_end .
+func (p *parser) parseHeredocBody(h *ast.Heredoc) error {
+	for {
+		if !p.match(token.HeredocBody) {
+			return fmt.Errorf("expected heredoc body, received %s", p.pTok)
+		}
+
+		h.Components = append(h.Components, p.current())
+
+		if p.match(token.HeredocEnd) {
+			h.Terminator = p.current()
+			return nil
+		}
+
+		if !p.match(token.LeftBrace) {
+			return fmt.Errorf("expected '{', received %s", p.pTok)
+		}
+
+		expr, err := p.parseExpression()
+		if err != nil {
+			return err
+		}
+
+		h.Expressions = append(h.Expressions, expr)
+
+		if !p.match(token.RightBrace) {
+			return fmt.Errorf("expected '}', received %s", p.pTok)
+		}
+	}
+}
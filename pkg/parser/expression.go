@@ -14,8 +14,10 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"laptudirm.com/x/mash/pkg/ast"
 	"laptudirm.com/x/mash/pkg/token"
@@ -249,8 +251,9 @@ func (p *parser) parseIndex(expr ast.Expression) (ast.Expression, error) {
 	}
 
 	return &ast.GetExpression{
-		Name: name,
-		Expr: expr,
+		Name:    name,
+		Expr:    expr,
+		Closing: p.current(),
 	}, nil
 }
 
@@ -270,13 +273,16 @@ func (p *parser) parseArguments(expr ast.Expression) (ast.Expression, error) {
 		Callee:      expr,
 		Parenthesis: paren,
 		Arguments:   args,
+		Closing:     p.current(),
 	}, nil
 }
 
-// Operand = Literal | "(" Expression ")" .
+// Operand = Literal | "(" Expression ")" | Subst .
 func (p *parser) parseOperand() (ast.Expression, error) {
 	switch {
 	case p.match(token.LeftParen):
+		opening := p.current()
+
 		expr, err := p.parseExpression()
 		if err != nil {
 			return nil, err
@@ -286,7 +292,13 @@ func (p *parser) parseOperand() (ast.Expression, error) {
 			return nil, fmt.Errorf("expected ')', received %s", p.pTok)
 		}
 
-		return expr, nil
+		return &ast.GroupExpression{
+			Opening: opening,
+			Right:   expr,
+			Closing: p.current(),
+		}, nil
+	case p.check(token.Subst, token.SubstResult):
+		return p.parseSubst()
 	default:
 		return p.parseLiteral()
 	}
@@ -295,7 +307,7 @@ func (p *parser) parseOperand() (ast.Expression, error) {
 // Literal = BasicLit | CompositeLit | FunctionLit .
 func (p *parser) parseLiteral() (ast.Expression, error) {
 	switch p.pTok {
-	case token.Identifier, token.Number, token.String:
+	case token.Identifier, token.Int, token.Float, token.String:
 		return p.parseBasicLit()
 	case token.LeftBrack:
 		return p.parseArrayLit()
@@ -310,38 +322,82 @@ func (p *parser) parseLiteral() (ast.Expression, error) {
 	}
 }
 
-// BasicLit = identifier | number_lit | string_lit .
+// BasicLit = identifier | int_lit | float_lit | string_lit .
 func (p *parser) parseBasicLit() (ast.Expression, error) {
-	switch p.next(); p.tok {
+	// literals are checked before they are consumed, so that errors are
+	// reported at their position
+	switch p.pTok {
 	case token.Identifier:
+		p.next()
 		return &ast.VariableExpression{
 			Name: p.current(),
 		}, nil
-	case token.Number:
-		val, err := strconv.ParseFloat(p.lit, 64)
+	case token.Int:
+		val, err := strconv.ParseInt(p.pLit, 0, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, fmt.Errorf("integer literal %s overflows int64", p.pLit)
+		}
+
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("invalid integer literal %s", p.pLit)
+		}
+
+		p.next()
+		return &ast.IntLiteral{
+			Token: p.current(),
+			Value: val,
+		}, nil
+	case token.Float:
+		val, err := strconv.ParseFloat(p.pLit, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid floating point literal %s", p.pLit)
 		}
 
-		return &ast.NumberLiteral{
+		p.next()
+		return &ast.FloatLiteral{
 			Token: p.current(),
 			Value: val,
 		}, nil
 	case token.String:
-		val, err := strconv.Unquote(p.lit)
+		val, err := unquote(p.pLit)
 		if err != nil {
 			return nil, err
 		}
 
+		p.next()
 		return &ast.StringLiteral{
-			Token: p.current(),
-			Value: val,
+			Token:  p.current(),
+			Value:  val,
+			Quoted: true,
 		}, nil
 	default:
 		panic("invalid token provided to BasicLit")
 	}
 }
 
+// unquote interprets the quoted string s like strconv.Unquote, except that
+// the escape sequence \$ is allowed inside "strings", and denotes a '$'.
+func unquote(s string) (string, error) {
+	if !strings.HasPrefix(s, `"`) || !strings.Contains(s, `\$`) {
+		return strconv.Unquote(s)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] != '$' {
+				// other escapes are interpreted by strconv
+				b.WriteByte('\\')
+			}
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return strconv.Unquote(b.String())
+}
+
 // ArrayLit = "[" ExpressionList "]" .
 func (p *parser) parseArrayLit() (*ast.ArrayLiteral, error) {
 	p.match(token.LeftBrack)
@@ -355,6 +411,7 @@ func (p *parser) parseArrayLit() (*ast.ArrayLiteral, error) {
 	return &ast.ArrayLiteral{
 		Token:    brack,
 		Elements: list,
+		Closing:  p.current(),
 	}, nil
 }
 
@@ -397,28 +454,84 @@ func (p *parser) parseObjectLit() (*ast.ObjectLiteral, error) {
 	return &ast.ObjectLiteral{
 		Token:    obj,
 		Elements: elements,
+		Closing:  p.current(),
 	}, nil
 }
 
-// FunctionLit = "func" Block .
+// FunctionLit = "func" [ Parameters ] Block .
 func (p *parser) parseFunctionLit() (*ast.FunctionLiteral, error) {
 	p.match(token.Func)
-	tok := p.current()
+	return p.parseFunction(p.current())
+}
+
+// parseFunction parses the parameters and the block of a function, whose
+// func keyword is tok.
+func (p *parser) parseFunction(tok token.Token) (*ast.FunctionLiteral, error) {
+	fn := &ast.FunctionLiteral{
+		Token: tok,
+	}
+
+	if p.check(token.LeftParen) {
+		var err error
+		if fn.Parameters, fn.Variadic, err = p.parseParameters(); err != nil {
+			return nil, err
+		}
+	}
 
 	block, err := p.parseBlock()
 	if err != nil {
 		return nil, err
 	}
 
-	return &ast.FunctionLiteral{
-		Token: tok,
-		Block: block,
-	}, nil
+	fn.Block = block
+	return fn, nil
+}
+
+// Parameters = "(" [ Parameter { "," Parameter } [ "," ] ] ")" .
+// Parameter  = identifier [ "..." ] .
+func (p *parser) parseParameters() ([]token.Token, bool, error) {
+	if !p.match(token.LeftParen) {
+		return nil, false, fmt.Errorf("expected '(', received %s", p.pTok)
+	}
+
+	var params []token.Token
+	var variadic bool
+
+	for !p.check(token.RightParen) && !p.atEnd() {
+		if variadic {
+			return nil, false, fmt.Errorf("can only use ... with final parameter")
+		}
+
+		if !p.match(token.Identifier) {
+			return nil, false, fmt.Errorf("expected identifier, received %s", p.pTok)
+		}
+
+		param := p.current()
+		for _, prev := range params {
+			if prev.Literal == param.Literal {
+				return nil, false, fmt.Errorf("duplicate parameter %s", param.Literal)
+			}
+		}
+
+		params = append(params, param)
+		variadic = p.match(token.Ellipsis)
+
+		if !p.match(token.Comma) && !p.check(token.RightParen) {
+			return nil, false, fmt.Errorf("expected ')', received %s", p.pTok)
+		}
+	}
+
+	if !p.match(token.RightParen) {
+		return nil, false, fmt.Errorf("expected ')', received %s", p.pTok)
+	}
+
+	return params, variadic, nil
 }
 
 // TemplateLit = "'" _embedded_string_val "'" .
 func (p *parser) parseTemplateLit() (*ast.TemplateLiteral, error) {
 	p.match(token.Template)
+	tok := p.current()
 
 	var components []token.Token
 	var expressions []ast.Expression
@@ -444,8 +557,10 @@ func (p *parser) parseTemplateLit() (*ast.TemplateLiteral, error) {
 	}
 
 	return &ast.TemplateLiteral{
+		Token:       tok,
 		Expressions: expressions,
 		Components:  components,
+		Closing:     p.current(),
 	}, nil
 }
 
@@ -14,47 +14,108 @@
 package parser
 
 import (
+	"context"
+
 	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/diagnostics"
 	"laptudirm.com/x/mash/pkg/lexer"
 	"laptudirm.com/x/mash/pkg/token"
 )
 
+// Mode is a set of flags which control optional parser functionality.
+type Mode uint
+
+const (
+	// ParseComments makes the parser collect the comments in the source
+	// and associate them with the nodes of the syntax tree. Otherwise,
+	// comments are ignored.
+	ParseComments Mode = 1 << iota
+)
+
 type parser struct {
-	tokens lexer.TokenStream
+	fset   *token.FileSet
+	file   *token.File
+	tokens lexer.TokenSource
+	mode   Mode
+
+	done      <-chan struct{} // closed when parsing is cancelled
+	cancelled bool
 
 	// next "peek" token
 	pTok token.Type
-	pPos token.Position
+	pPos token.Pos
 	pLit string
 
 	// current token
 	tok token.Type
-	pos token.Position
+	pos token.Pos
 	lit string
 
-	err lexer.ErrorHandler
+	errors diagnostics.ErrorList
 
-	ErrorCount int
+	// heredocs whose bodies have not been parsed
+	heredocs []*ast.Heredoc
+
+	// list of all comments in the source
+	comments []*ast.CommentGroup
+}
+
+// Parse parses the mash source src of the file filename into a program.
+// The file is added to the file set fset, which is used to resolve the
+// positions of the program's nodes. If any errors are encountered while
+// lexing or parsing the source, the returned error is a
+// diagnostics.ErrorList, sorted by position with at most one error on
+// each line, and the program contains the statements without errors.
+func Parse(fset *token.FileSet, filename, src string, mode Mode) (*ast.Program, error) {
+	return ParseContext(context.Background(), fset, filename, src, mode)
 }
 
-func Parse(t lexer.TokenStream, e lexer.ErrorHandler) *ast.Program {
-	p := parser{
-		tokens:     t,
-		err:        e,
-		ErrorCount: 0,
+// ParseContext is like Parse, but the parsing is stopped when the context
+// ctx is cancelled. In that case, the statements parsed till then are
+// returned as a partial program, along with ctx.Err(). Errors are not
+// reported after the cancellation.
+func ParseContext(ctx context.Context, fset *token.FileSet, filename, src string, mode Mode) (*ast.Program, error) {
+	p := &parser{
+		fset: fset,
+		file: fset.AddFile(filename, src),
+		mode: mode,
+		done: ctx.Done(),
+	}
+
+	p.tokens = lexer.NewScanner(p.file, p.lexError)
+
+	program := p.parse()
+	if p.cancelled {
+		return program, ctx.Err()
 	}
 
+	p.errors.RemoveMultiples()
+	return program, p.errors.Err()
+}
+
+// parse parses the tokens from the parser's token source into a program.
+func (p *parser) parse() *ast.Program {
 	// start token consumption
 	p.next()
 
-	return p.parseProgram()
+	program := p.parseProgram()
+	if p.mode&ParseComments != 0 {
+		program.Comments = p.comments
+		program.CommentMap = ast.NewCommentMap(p.fset, program, p.comments)
+	}
+
+	if !p.cancelled {
+		p.validate(program)
+	}
+
+	return program
 }
 
 func (p *parser) current() token.Token {
 	return token.Token{
-		Type:     p.tok,
-		Position: p.pos,
-		Literal:  p.lit,
+		Type:    p.tok,
+		Pos:     p.pos,
+		Literal: p.lit,
 	}
 }
 
@@ -82,26 +143,89 @@ func (p *parser) atEnd() bool {
 }
 
 func (p *parser) next() {
-	tok := <-p.tokens
+	tok := p.read()
 
 	p.tok = p.pTok
 	p.pos = p.pPos
 	p.lit = p.pLit
 
+	// comments are collected separately from the syntax tree
+	var group *ast.CommentGroup
 	for tok.Type == token.Comment {
-		tok = <-p.tokens
+		if p.mode&ParseComments != 0 {
+			group = p.comment(group, tok)
+		}
+
+		tok = p.read()
 	}
 
 	p.pTok = tok.Type
-	p.pPos = tok.Position
+	p.pPos = tok.Pos
 	p.pLit = tok.Literal
 }
 
-func (p *parser) error(pos token.Position, err error) {
-	p.ErrorCount++
-	if p.err != nil {
-		p.err(pos, err)
+// read reads the next token from the token source. EOF tokens without a
+// position, like those from a closed token stream, are positioned at the
+// previous EOF token. After parsing is cancelled, only EOF tokens are
+// returned.
+func (p *parser) read() token.Token {
+	select {
+	case <-p.done:
+		p.cancelled = true
+		return token.Token{Type: token.Eof, Pos: p.pPos}
+	default:
+	}
+
+	tok := p.tokens.Next()
+	if tok.Type == token.Eof && tok.Pos == token.NoPos {
+		tok.Pos = p.pPos
 	}
+
+	return tok
+}
+
+// comment records the comment tok. It is added to group if it is on the
+// line after the group's last comment, otherwise a new group is started.
+// The group which the comment was added to is returned.
+func (p *parser) comment(group *ast.CommentGroup, tok token.Token) *ast.CommentGroup {
+	c := &ast.Comment{Token: tok}
+
+	if group != nil && p.file.Line(group.End())+1 == p.file.Line(tok.Pos) {
+		group.List = append(group.List, c)
+		return group
+	}
+
+	group = &ast.CommentGroup{List: []*ast.Comment{c}}
+	p.comments = append(p.comments, group)
+	return group
+}
+
+// error reports the syntax error err at the position pos.
+func (p *parser) error(pos token.Pos, err error) {
+	p.diagnose(&diagnostics.Error{
+		Position: p.file.Position(pos),
+		Code:     diagnostics.CodeSyntax,
+		Message:  err.Error(),
+	})
+}
+
+// lexError reports the error err from the lexer at the position pos.
+func (p *parser) lexError(pos token.Position, err error) {
+	p.diagnose(&diagnostics.Error{
+		Position: pos,
+		Code:     diagnostics.CodeLexical,
+		Message:  err.Error(),
+	})
+}
+
+// diagnose adds the diagnostic d to the parser's error list.
+func (p *parser) diagnose(d *diagnostics.Error) {
+	if p.cancelled {
+		// errors caused by the cancellation
+		return
+	}
+
+	p.errors = append(p.errors, d)
 }
 
 func (p *parser) synchronize() {
@@ -116,7 +240,7 @@ func (p *parser) synchronize() {
 
 		switch p.pTok {
 		// check for tokens which start a statement
-		case token.For, token.If, token.Let, token.Break, token.Continue, token.Return:
+		case token.For, token.If, token.Let, token.Func, token.Break, token.Continue, token.Return:
 			return
 		default:
 			p.next()
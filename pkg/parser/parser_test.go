@@ -0,0 +1,501 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/diagnostics"
+	"laptudirm.com/x/mash/pkg/lexer"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		src  string
+		code string
+		want string
+		fix  string // source text removed by the fix
+	}{
+		{"break", diagnostics.CodeBreak, "1:1: break is not in a loop", "break"},
+		{"if true {\n\tcontinue\n}", diagnostics.CodeContinue, "2:2: continue is not in a loop", "continue"},
+		{"return 1", diagnostics.CodeReturn, "1:1: return is not in a function", "return 1"},
+		{"for {\n\tlet f = func {\n\t\tbreak\n\t}\n}", diagnostics.CodeBreak, "3:3: break is not in a loop", "break"},
+		{"func f() {\n\tlet g = func { return }\n}\nfor { let x = len(func { continue }) }", diagnostics.CodeContinue, "4:26: continue is not in a loop", "continue"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(token.NewFileSet(), "", test.src, 0)
+
+		list, ok := err.(diagnostics.ErrorList)
+		if !ok || len(list) != 1 {
+			t.Errorf("%q: expected 1 error, received %v", test.src, err)
+			continue
+		}
+
+		e := list[0]
+		if e.Error() != test.want || e.Code != test.code {
+			t.Errorf("%q: expected %s [%s], received %s [%s]", test.src, test.want, test.code, e, e.Code)
+		}
+
+		if e.Fix == nil || test.src[e.Fix.Pos.Offset:e.Fix.End.Offset] != test.fix {
+			t.Errorf("%q: expected fix removing %q, received %+v", test.src, test.fix, e.Fix)
+		}
+	}
+
+	valid := []string{
+		"for { break }",
+		"for { if true { continue } }",
+		"func f() { return 1 }",
+		"let f = func { for { return } }",
+	}
+
+	for _, src := range valid {
+		if _, err := Parse(token.NewFileSet(), "", src, 0); err != nil {
+			t.Errorf("%q: unexpected error %s", src, err)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	// the lexical error on line 3 is reported before the syntax error
+	// after it, and only the first error on each line is kept
+	src := "let x = )\nlet y = 1\nlet z = \"a\x00\" + )\nbreak"
+	want := []struct {
+		code string
+		msg  string
+	}{
+		{diagnostics.CodeSyntax, "1:9: invalid literal )"},
+		{diagnostics.CodeLexical, "3:11: illegal character NUL"},
+		{diagnostics.CodeBreak, "4:1: break is not in a loop"},
+	}
+
+	program, err := Parse(token.NewFileSet(), "", src, 0)
+
+	list, ok := err.(diagnostics.ErrorList)
+	if !ok || len(list) != len(want) {
+		t.Fatalf("expected %d errors, received %v", len(want), err)
+	}
+
+	for i, e := range list {
+		if e.Code != want[i].code || e.Error() != want[i].msg {
+			t.Errorf("error %d: expected %s [%s], received %s [%s]", i, want[i].msg, want[i].code, e, e.Code)
+		}
+	}
+
+	// statements without errors are parsed
+	if len(program.Statements) != 2 {
+		t.Errorf("expected 2 statements, received %d", len(program.Statements))
+	}
+}
+
+func TestSpan(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string // source text of every node, in depth-first order
+	}{
+		{
+			"let x = -a.b[1] + (2)",
+			[]string{
+				"let x = -a.b[1] + (2)",
+				"x = -a.b[1] + (2)",
+				"x",
+				"-a.b[1] + (2)",
+				"-a.b[1]",
+				"a.b[1]",
+				"a.b",
+				"a",
+				"1",
+				"(2)",
+				"2",
+			},
+		},
+		{
+			"let f(\"s\", 'a{b}c') || [] && obj[]",
+			[]string{
+				"let f(\"s\", 'a{b}c') || [] && obj[]",
+				"f(\"s\", 'a{b}c') || [] && obj[]",
+				"f(\"s\", 'a{b}c')",
+				"f",
+				"\"s\"",
+				"'a{b}c'",
+				"b",
+				"[] && obj[]",
+				"[]",
+				"obj[]",
+			},
+		},
+		{
+			"if a {\n\tlet x\n} else if b {} else {\n}",
+			[]string{
+				"if a {\n\tlet x\n} else if b {} else {\n}",
+				"a",
+				"{\n\tlet x\n}",
+				"let x",
+				"x",
+				"if b {} else {\n}",
+				"b",
+				"{}",
+				"{\n}",
+			},
+		},
+		{
+			"for {\n\tcontinue\n}\nfunc f(a) {\n\treturn a\n}\nlet g = func { return }",
+			[]string{
+				"for {\n\tcontinue\n}",
+				"{\n\tcontinue\n}",
+				"continue",
+				"func f(a) {\n\treturn a\n}",
+				"func f(a) {\n\treturn a\n}",
+				"{\n\treturn a\n}",
+				"return a",
+				"a",
+				"let g = func { return }",
+				"g = func { return }",
+				"g",
+				"func { return }",
+				"{ return }",
+				"return",
+			},
+		},
+		{
+			"! echo a 'b{c}' >out | cat <<EOF && true\nbody\nEOF\n",
+			[]string{
+				"! echo a 'b{c}' >out | cat <<EOF && true",
+				"! echo a 'b{c}' >out | cat <<EOF && true",
+				"! echo a 'b{c}' >out | cat <<EOF",
+				"echo a 'b{c}' >out | cat <<EOF",
+				"echo a 'b{c}' >out",
+				"echo",
+				"a",
+				"'b{c}'",
+				"c",
+				">out",
+				"out",
+				"cat <<EOF",
+				"cat",
+				"<<EOF",
+				"true",
+				"true",
+			},
+		},
+		{
+			"echo $A/b \"x $B\\$ ${C?}\" >$D",
+			[]string{
+				"echo $A/b \"x $B\\$ ${C?}\" >$D",
+				"echo $A/b \"x $B\\$ ${C?}\" >$D",
+				"echo",
+				"$A/b",
+				"$A",
+				"/b",
+				"\"x $B\\$ ${C?}\"",
+				"\"x ",
+				"$B",
+				"\\$ ",
+				"${C?}",
+				"\"",
+				">$D",
+				"$D",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		program, err := Parse(fset, "", test.src, 0)
+		if err != nil {
+			t.Fatalf("%q: %s", test.src, err)
+		}
+
+		var got []string
+		ast.Inspect(program, func(n ast.Node) bool {
+			if _, ok := n.(*ast.Program); n != nil && !ok {
+				got = append(got, test.src[fset.Position(n.Pos()).Offset:fset.Position(n.End()).Offset])
+			}
+			return true
+		})
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: unexpected spans:\nreceived %q\nexpected %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	src := "# a\n# b\n\n# c\nlet x = 1 # d\n# e\nif x {\n\t# f\n}"
+	want := [][]string{{"# a", "# b"}, {"# c"}, {"# d"}, {"# e"}, {"# f"}}
+
+	program, err := Parse(token.NewFileSet(), "", src, ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][]string
+	for _, group := range program.Comments {
+		var list []string
+		for _, c := range group.List {
+			list = append(list, c.Token.Literal)
+		}
+
+		got = append(got, list)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected comment groups %q, received %q", want, got)
+	}
+	// comments are only collected in the ParseComments mode
+	program, _ = Parse(token.NewFileSet(), "", src, 0)
+	if program.Comments != nil || program.CommentMap != nil {
+		t.Errorf("expected no comments without ParseComments, received %d groups", len(program.Comments))
+	}
+}
+
+// cancelSource is a token source which cancels a context after reading n
+// tokens.
+type cancelSource struct {
+	lexer.TokenSource
+	n      int
+	cancel context.CancelFunc
+}
+
+func (s *cancelSource) Next() token.Token {
+	if s.n--; s.n == 0 {
+		s.cancel()
+	}
+
+	return s.TokenSource.Next()
+}
+
+func TestParseContext(t *testing.T) {
+	src := "let x = 1\nlet y = 2\nlet z = (3 +"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fset := token.NewFileSet()
+	p := &parser{fset: fset, file: fset.AddFile("", src), done: ctx.Done()}
+
+	// cancelled after reading the tokens of the first statement, and the
+	// peeked let of the second statement
+	p.tokens = &cancelSource{lexer.NewScanner(p.file, p.lexError), 6, cancel}
+
+	program := p.parse()
+	if !p.cancelled || len(p.errors) != 0 {
+		t.Errorf("expected cancellation without errors, received %v", p.errors)
+	}
+
+	if len(program.Statements) != 1 {
+		t.Errorf("expected 1 statement in partial program, received %d", len(program.Statements))
+	}
+
+	if _, err := ParseContext(ctx, fset, "", src, 0); err != context.Canceled {
+		t.Errorf("expected error %v, received %v", context.Canceled, err)
+	}
+
+	program, err := ParseContext(context.Background(), fset, "", "let x = 1", 0)
+	if err != nil || len(program.Statements) != 1 {
+		t.Errorf("expected 1 statement and no error, received %d and %v", len(program.Statements), err)
+	}
+}
+
+func TestEnvExpansion(t *testing.T) {
+	program, err := Parse(token.NewFileSet(), "", `echo "a\$ $B\t"${C?}`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := program.Statements[0].(*ast.CmdStatement).Command.(*ast.LiteralCommand)
+	word, ok := cmd.Components[1].(*ast.Word)
+	if !ok {
+		t.Fatalf("expected word argument, received %T", cmd.Components[1])
+	}
+
+	var got []string
+	for _, part := range word.Parts {
+		switch part := part.(type) {
+		case *ast.StringLiteral:
+			got = append(got, strconv.Quote(part.Value))
+		case *ast.EnvExpansion:
+			got = append(got, fmt.Sprintf("env %s %t", part.Name, part.Required))
+		}
+	}
+
+	want := []string{`"a$ "`, "env B false", `"\t"`, "env C true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected parts %q, received %q", want, got)
+	}
+
+	for _, src := range []string{`echo "${1}"`, `echo "${A"`, "echo ${}"} {
+		if _, err := Parse(token.NewFileSet(), "", src, 0); err == nil {
+			t.Errorf("%q: expected invalid environment variable error", src)
+		}
+	}
+}
+
+func TestQuoted(t *testing.T) {
+	program, err := Parse(token.NewFileSet(), "", "echo *.go \"*.go\" `*`x", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := program.Statements[0].(*ast.CmdStatement).Command.(*ast.LiteralCommand)
+	word := cmd.Components[3].(*ast.Word)
+
+	var got []bool
+	for _, arg := range []ast.CommandComponent{cmd.Components[1], cmd.Components[2], word.Parts[0], word.Parts[1]} {
+		got = append(got, arg.(*ast.StringLiteral).Quoted)
+	}
+
+	want := []bool{false, true, true, false}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected quoted %v, received %v", want, got)
+	}
+}
+
+func TestExpansions(t *testing.T) {
+	program, err := Parse(token.NewFileSet(), "", "cp ~/conf/{a,b{1..3}}.yml ~user x~ {a}", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := program.Statements[0].(*ast.CmdStatement).Command.(*ast.LiteralCommand)
+
+	var got []string
+	for _, c := range cmd.Components[1:] {
+		ast.Inspect(c, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.StringLiteral:
+				got = append(got, strconv.Quote(n.Value))
+			case *ast.TildeExpansion:
+				got = append(got, "~"+n.User)
+			case *ast.BraceExpansion:
+				got = append(got, fmt.Sprintf("brace %d", len(n.Elements)))
+			case *ast.BraceRange:
+				got = append(got, fmt.Sprintf("range %d %d %d", n.From, n.To, n.Incr))
+			}
+
+			return true
+		})
+	}
+
+	want := []string{"~", `"/conf/"`, "brace 2", `"a"`, `"b"`, "range 1 3 1", `".yml"`, "~user", `"x~"`, `"{a}"`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected parts %q, received %q", want, got)
+	}
+}
+
+func TestSubst(t *testing.T) {
+	program, err := Parse(token.NewFileSet(), "", "let x = $(git log | head) + $?(ls)\necho a$(pwd) &", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	let := program.Statements[0].(*ast.LetStatement)
+	binary := let.Expression.(*ast.AssignExpression).Right.(*ast.BinaryExpression)
+
+	left, ok := binary.Left.(*ast.CommandSubstitution)
+	if !ok || left.Token.Type != token.Subst {
+		t.Errorf("expected $( substitution, received %T", binary.Left)
+	} else if _, ok := left.Command.(*ast.BinaryCommand); !ok {
+		t.Errorf("expected pipeline inside substitution, received %T", left.Command)
+	}
+
+	if right, ok := binary.Right.(*ast.CommandSubstitution); !ok || right.Token.Type != token.SubstResult {
+		t.Errorf("expected $?( substitution, received %T", binary.Right)
+	}
+
+	stmt := program.Statements[1].(*ast.CmdStatement)
+	if stmt.Background.Type != token.And {
+		t.Errorf("expected background command")
+	}
+
+	word := stmt.Command.(*ast.LiteralCommand).Components[1].(*ast.Word)
+	if _, ok := word.Parts[1].(*ast.CommandSubstitution); !ok {
+		t.Errorf("expected substitution in command argument, received %T", word.Parts[1])
+	}
+
+	for _, src := range []string{"let x = $(echo a", "echo a & b", "let x = $()"} {
+		if _, err := Parse(token.NewFileSet(), "", src, 0); err == nil {
+			t.Errorf("%q: expected syntax error", src)
+		}
+	}
+}
+
+func TestSubshell(t *testing.T) {
+	src := "(echo a; echo b) | grep b && (\n\tlet x = 1\n\tpwd\n) > out\n"
+	program, err := Parse(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("expected 1 statement, received %d", len(program.Statements))
+	}
+
+	logical := program.Statements[0].(*ast.CmdStatement).Command.(*ast.LogicalCommand)
+
+	pipe := logical.Left.(*ast.BinaryCommand)
+	if left, ok := pipe.Left.(*ast.SubshellCommand); !ok || len(left.Statements) != 2 {
+		t.Errorf("expected subshell with 2 statements as pipeline stage, received %T", pipe.Left)
+	}
+
+	right, ok := logical.Right.(*ast.SubshellCommand)
+	if !ok {
+		t.Fatalf("expected subshell, received %T", logical.Right)
+	}
+
+	if len(right.Statements) != 2 || len(right.Redirects) != 1 {
+		t.Errorf("expected 2 statements and 1 redirect, received %d and %d", len(right.Statements), len(right.Redirects))
+	}
+
+	if _, ok := right.Statements[0].(*ast.LetStatement); !ok {
+		t.Errorf("expected let statement inside subshell, received %T", right.Statements[0])
+	}
+
+	for _, src := range []string{"(echo a", "(echo a) b", "(break)"} {
+		if _, err := Parse(token.NewFileSet(), "", src, 0); err == nil {
+			t.Errorf("%q: expected syntax error", src)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	program, err := Parse(token.NewFileSet(), "", "let x = 0x10 + 017 + 1.5e1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expr := program.Statements[0].(*ast.LetStatement).Expression.(*ast.AssignExpression).Right.(*ast.BinaryExpression)
+	ints := expr.Left.(*ast.BinaryExpression)
+
+	if lit, ok := ints.Left.(*ast.IntLiteral); !ok || lit.Value != 16 {
+		t.Errorf("expected integer literal 16, received %#v", ints.Left)
+	}
+
+	if lit, ok := ints.Right.(*ast.IntLiteral); !ok || lit.Value != 15 {
+		t.Errorf("expected integer literal 15, received %#v", ints.Right)
+	}
+
+	if lit, ok := expr.Right.(*ast.FloatLiteral); !ok || lit.Value != 15 {
+		t.Errorf("expected float literal 15, received %#v", expr.Right)
+	}
+
+	tests := []struct {
+		src string
+		msg string
+	}{
+		{"let x = 09", "1:9: invalid integer literal 09"},
+		{"let x = 9223372036854775808", "1:9: integer literal 9223372036854775808 overflows int64"},
+		{"let x = 0x1.8", "1:9: invalid floating point literal 0x1.8"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(token.NewFileSet(), "", test.src, 0)
+		if err == nil || err.Error() != test.msg {
+			t.Errorf("%q: expected error %q, received %v", test.src, test.msg, err)
+		}
+	}
+}
@@ -33,13 +33,17 @@ func (p *parser) parseBlock() (*ast.BlockStatement, error) {
 		return nil, fmt.Errorf("expected '{', received %s", p.pTok)
 	}
 
+	opening := p.current()
+
 	statements := p.parseStatementList(token.RightBrace)
 	if !p.match(token.RightBrace) {
 		return nil, fmt.Errorf("expected '}', received %s", p.pTok)
 	}
 
 	return &ast.BlockStatement{
+		Opening:    opening,
 		Statements: statements,
+		Closing:    p.current(),
 	}, nil
 }
 
@@ -62,7 +66,7 @@ func (p *parser) parseStatementList(eos token.Type) []ast.Statement {
 	return statements
 }
 
-// Statement = ( LetStatement | ForStatement | IfStatement | Block | CommandStatement ) ";" .
+// Statement = ( LetStatement | FuncStatement | ForStatement | IfStatement | BreakStatement | ContinueStatement | ReturnStatement | Block | CommandStatement ) ";" .
 func (p *parser) parseStatement() (ast.Statement, error) {
 	var stmt ast.Statement
 	var err error
@@ -70,13 +74,23 @@ func (p *parser) parseStatement() (ast.Statement, error) {
 	switch p.pTok {
 	case token.Let:
 		stmt, err = p.parseLetStatement()
+	case token.Func:
+		stmt, err = p.parseFuncStatement()
 	case token.For:
 		stmt, err = p.parseForStatement()
 	case token.If:
 		stmt, err = p.parseIfStatement()
+	case token.Break:
+		stmt, err = p.parseBreakStatement()
+	case token.Continue:
+		stmt, err = p.parseContinueStatement()
+	case token.Return:
+		stmt, err = p.parseReturnStatement()
 	case token.LeftBrace:
 		stmt, err = p.parseBlock()
-	case token.String, token.Not:
+	case token.String, token.EnvVar, token.Subst, token.SubstResult, token.LeftParen, token.Not, token.RedirectIn, token.RedirectOut,
+		token.RedirectAppend, token.RedirectAll, token.RedirectDup, token.HereString,
+		token.Heredoc:
 		stmt, err = p.parseCommandStatement()
 	default:
 		return nil, fmt.Errorf("illegal token %s at line start", p.pTok)
@@ -95,22 +109,56 @@ func (p *parser) parseLetStatement() (*ast.LetStatement, error) {
 		return nil, fmt.Errorf("expected 'let', received %s", p.pTok)
 	}
 
+	tok := p.current()
+
 	expr, err := p.parseAssignExpression()
 	if err != nil {
 		return nil, err
 	}
 
 	return &ast.LetStatement{
+		Token:      tok,
 		Expression: expr,
 	}, nil
 }
 
+// FuncStatement = "func" identifier Parameters Block .
+func (p *parser) parseFuncStatement() (*ast.FuncStatement, error) {
+	if !p.match(token.Func) {
+		return nil, fmt.Errorf("expected 'func', received %s", p.pTok)
+	}
+
+	tok := p.current()
+
+	if !p.match(token.Identifier) {
+		return nil, fmt.Errorf("expected function name, received %s", p.pTok)
+	}
+
+	name := p.current()
+
+	if !p.check(token.LeftParen) {
+		return nil, fmt.Errorf("expected '(', received %s", p.pTok)
+	}
+
+	fn, err := p.parseFunction(tok)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.FuncStatement{
+		Name:     name,
+		Function: fn,
+	}, nil
+}
+
 // ForStatement = "for" [ Expression ] Block .
 func (p *parser) parseForStatement() (*ast.ForStatement, error) {
 	if !p.match(token.For) {
 		return nil, fmt.Errorf("expected 'for', received %s", p.pTok)
 	}
 
+	tok := p.current()
+
 	var condition ast.Expression
 	var err error
 
@@ -127,6 +175,7 @@ func (p *parser) parseForStatement() (*ast.ForStatement, error) {
 	}
 
 	return &ast.ForStatement{
+		Token:     tok,
 		Condition: condition,
 		BlockStmt: block,
 	}, nil
@@ -138,6 +187,8 @@ func (p *parser) parseIfStatement() (*ast.IfStatement, error) {
 		return nil, fmt.Errorf("expected 'if', received %s", p.pTok)
 	}
 
+	tok := p.current()
+
 	cond, err := p.parseExpression()
 	if err != nil {
 		return nil, err
@@ -171,20 +222,84 @@ func (p *parser) parseIfStatement() (*ast.IfStatement, error) {
 	}
 
 	return &ast.IfStatement{
+		Token:     tok,
 		Condition: cond,
 		BlockStmt: block,
 		ElseBlock: elseBlock,
 	}, nil
 }
 
-// CommandStatement = OrCommand .
+// BreakStatement = "break" .
+func (p *parser) parseBreakStatement() (*ast.BreakStatement, error) {
+	if !p.match(token.Break) {
+		return nil, fmt.Errorf("expected 'break', received %s", p.pTok)
+	}
+
+	return &ast.BreakStatement{
+		Token: p.current(),
+	}, nil
+}
+
+// ContinueStatement = "continue" .
+func (p *parser) parseContinueStatement() (*ast.ContinueStatement, error) {
+	if !p.match(token.Continue) {
+		return nil, fmt.Errorf("expected 'continue', received %s", p.pTok)
+	}
+
+	return &ast.ContinueStatement{
+		Token: p.current(),
+	}, nil
+}
+
+// ReturnStatement = "return" [ Expression ] .
+func (p *parser) parseReturnStatement() (*ast.ReturnStatement, error) {
+	if !p.match(token.Return) {
+		return nil, fmt.Errorf("expected 'return', received %s", p.pTok)
+	}
+
+	stmt := &ast.ReturnStatement{
+		Token: p.current(),
+	}
+
+	if !p.check(token.Semicolon, token.RightBrace) {
+		result, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		stmt.Result = result
+	}
+
+	return stmt, nil
+}
+
+// CommandStatement = OrCommand [ "&" ] .
 func (p *parser) parseCommandStatement() (*ast.CmdStatement, error) {
 	cmd, err := p.parseOrCommand()
 	if err != nil {
+		// bodies will be skipped while synchronizing
+		p.heredocs = nil
+		return nil, err
+	}
+
+	// command is run in the background
+	var background token.Token
+	if p.match(token.And) {
+		background = p.current()
+	}
+
+	if err := p.parseHeredocBodies(); err != nil {
 		return nil, err
 	}
 
+	if len(p.heredocs) > 0 {
+		h := p.heredocs[0]
+		p.heredocs = nil
+		return nil, fmt.Errorf("missing body of heredoc %s", h.Operator.Literal)
+	}
+
 	return &ast.CmdStatement{
-		Command: cmd,
+		Command:    cmd,
+		Background: background,
 	}, nil
 }
@@ -0,0 +1,87 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"fmt"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/diagnostics"
+)
+
+// validator checks the rules of the language which are not expressed by
+// it's grammar, like the placement of break, continue and return.
+type validator struct {
+	p *parser
+
+	loop bool // inside a for loop
+	fn   bool // inside a function
+}
+
+// validate validates the program, adding any errors to the parser's error
+// list.
+func (p *parser) validate(program *ast.Program) {
+	ast.Walk(&validator{p: p}, program)
+}
+
+// misplaced reports the statement stmt, which is not allowed at it's
+// position, with the code code. The removal of the statement is suggested
+// as a fix.
+func (v *validator) misplaced(stmt ast.Statement, code string, format string, a ...any) {
+	pos := v.p.file.Position(stmt.Pos())
+
+	v.p.diagnose(&diagnostics.Error{
+		Position: pos,
+		Code:     code,
+		Message:  fmt.Sprintf(format, a...),
+		Fix: &diagnostics.Fix{
+			Message: "remove the statement",
+			Pos:     pos,
+			End:     v.p.file.Position(stmt.End()),
+		},
+	})
+}
+
+func (v *validator) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.ForStatement:
+		if n.Condition != nil {
+			ast.Walk(v, n.Condition)
+		}
+
+		ast.Walk(&validator{p: v.p, loop: true, fn: v.fn}, n.BlockStmt)
+		return nil
+	case *ast.FunctionLiteral:
+		// loops outside the function can't be controlled from inside it
+		return &validator{p: v.p, fn: true}
+	case *ast.SubshellCommand:
+		// loops and functions outside the subshell can't be controlled
+		// from inside it
+		return &validator{p: v.p}
+	case *ast.BreakStatement:
+		if !v.loop {
+			v.misplaced(n, diagnostics.CodeBreak, "break is not in a loop")
+		}
+	case *ast.ContinueStatement:
+		if !v.loop {
+			v.misplaced(n, diagnostics.CodeContinue, "continue is not in a loop")
+		}
+	case *ast.ReturnStatement:
+		if !v.fn {
+			v.misplaced(n, diagnostics.CodeReturn, "return is not in a function")
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,138 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package printer
+
+import (
+	"laptudirm.com/x/mash/pkg/ast"
+)
+
+func (p *printer) command(cmd ast.Command) {
+	switch cmd := cmd.(type) {
+	case *ast.LogicalCommand:
+		p.command(cmd.Left)
+		p.print(" ", cmd.Operator.Literal, " ")
+		p.command(cmd.Right)
+	case *ast.BinaryCommand:
+		p.command(cmd.Left)
+		p.print(" ", cmd.Operator.Literal, " ")
+		p.command(cmd.Right)
+	case *ast.UnaryCommand:
+		p.print(cmd.Operator.Literal, " ")
+		p.command(cmd.Right)
+	case *ast.SubshellCommand:
+		p.subshell(cmd)
+	case *ast.LiteralCommand:
+		for i, c := range cmd.Components {
+			if i > 0 {
+				p.print(" ")
+			}
+
+			p.component(c)
+		}
+	}
+}
+
+// subshell prints the subshell command s. Subshells which only contain
+// command statements, and are on a single line without any comments, are
+// printed on a single line.
+func (p *printer) subshell(s *ast.SubshellCommand) {
+	if p.singleLine(s) {
+		p.print("(")
+		for i, stmt := range s.Statements {
+			if i > 0 {
+				p.print("; ")
+			}
+
+			p.statement(stmt)
+		}
+		p.print(")")
+	} else {
+		p.enclosed("(", ")", s.Opening.Pos, s.Statements, s.Closing.Pos)
+	}
+
+	for _, r := range s.Redirects {
+		p.print(" ")
+		p.component(r)
+	}
+}
+
+// singleLine reports whether the subshell s can be printed on a single
+// line. Only commands can be separated by semicolons on a single line.
+func (p *printer) singleLine(s *ast.SubshellCommand) bool {
+	if p.lineOf(s.Opening.Pos) != p.lineOf(s.Closing.Pos) || p.hasComments(s.Closing.Pos) {
+		return false
+	}
+
+	for _, stmt := range s.Statements {
+		if _, ok := stmt.(*ast.CmdStatement); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *printer) component(c ast.CommandComponent) {
+	switch c := c.(type) {
+	case *ast.StringLiteral:
+		p.print(c.Token.Literal)
+	case *ast.TemplateLiteral:
+		p.template(c.Components, c.Expressions)
+	case *ast.Word:
+		for _, part := range c.Parts {
+			p.component(part)
+		}
+	case *ast.EnvExpansion:
+		p.print(c.Token.Literal)
+	case *ast.CommandSubstitution:
+		p.subst(c)
+	case *ast.TildeExpansion:
+		p.print(c.Token.Literal)
+	case *ast.BraceRange:
+		p.print(c.Token.Literal)
+	case *ast.BraceExpansion:
+		p.print(c.Lbrace.Literal)
+		for i, elem := range c.Elements {
+			if i > 0 {
+				p.print(",")
+			}
+
+			p.component(elem)
+		}
+		p.print(c.Rbrace.Literal)
+	case *ast.Redirect:
+		p.print(c.Operator.Literal)
+		p.component(c.Target)
+	case *ast.Heredoc:
+		// body is printed after the end of the line
+		p.print(c.Operator.Literal)
+		p.heredocs = append(p.heredocs, c)
+	}
+}
+
+// heredocBody prints the body of the heredoc h, followed by it's
+// terminator, as it is present in the source.
+func (p *printer) heredocBody(h *ast.Heredoc) {
+	for i, c := range h.Components {
+		p.print(c.Literal)
+
+		if i < len(h.Expressions) {
+			p.print("{")
+			p.expression(h.Expressions[i])
+			p.print("}")
+		}
+	}
+
+	p.print(h.Terminator.Literal, "\n")
+}
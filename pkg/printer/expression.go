@@ -0,0 +1,227 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package printer
+
+import (
+	"sort"
+	"strings"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+func (p *printer) expression(expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.AssignExpression:
+		p.expression(expr.Left)
+		p.print(" ", expr.Operator.Literal, " ")
+		p.expression(expr.Right)
+	case *ast.LogicalExpression:
+		p.expression(expr.Left)
+		p.print(" ", expr.Operator.Literal, " ")
+		p.expression(expr.Right)
+	case *ast.BinaryExpression:
+		p.expression(expr.Left)
+		p.print(" ", expr.Operator.Literal, " ")
+		p.expression(expr.Right)
+	case *ast.UnaryExpression:
+		p.print(expr.Operator.Literal)
+		p.expression(expr.Right)
+	case *ast.GroupExpression:
+		p.print("(")
+		p.expression(expr.Right)
+		p.print(")")
+	case *ast.CallExpression:
+		p.expression(expr.Callee)
+		p.print("(")
+		p.expressionList(expr.Arguments)
+		p.print(")")
+	case *ast.GetExpression:
+		p.expression(expr.Expr)
+		p.print("[")
+		p.expression(expr.Name)
+		p.print("]")
+	case *ast.SelectorExpression:
+		p.expression(expr.Name)
+		p.print(".", expr.Index.Literal)
+	case *ast.VariableExpression:
+		p.print(expr.Name.Literal)
+
+	case *ast.IntLiteral:
+		p.print(expr.Token.Literal)
+	case *ast.FloatLiteral:
+		p.print(expr.Token.Literal)
+	case *ast.StringLiteral:
+		p.print(expr.Token.Literal)
+	case *ast.BooleanLiteral:
+		p.print(expr.Token.Literal)
+	case *ast.FunctionLiteral:
+		p.print("func")
+		if len(expr.Parameters) > 0 {
+			p.parameters(expr)
+		}
+
+		p.print(" ")
+		p.block(expr.Block)
+	case *ast.ArrayLiteral:
+		p.print("[")
+		if len(expr.Elements) > 0 && p.multiline(expr) {
+			nodes := make([]ast.Node, len(expr.Elements))
+			for i, elem := range expr.Elements {
+				nodes[i] = elem
+			}
+
+			p.entries(nodes, nodes, expr.Closing.Pos, func(i int) {
+				p.expression(expr.Elements[i])
+			})
+		} else {
+			p.expressionList(expr.Elements)
+		}
+		p.print("]")
+	case *ast.ObjectLiteral:
+		keys := objectKeys(expr)
+
+		p.print("obj[")
+		if len(keys) > 0 && p.multiline(expr) {
+			keyNodes := make([]ast.Node, len(keys))
+			valueNodes := make([]ast.Node, len(keys))
+			for i, key := range keys {
+				keyNodes[i], valueNodes[i] = key, expr.Elements[key]
+			}
+
+			p.entries(keyNodes, valueNodes, expr.Closing.Pos, func(i int) {
+				p.objectEntry(keys[i], expr.Elements[keys[i]])
+			})
+		} else {
+			for i, key := range keys {
+				if i > 0 {
+					p.print(", ")
+				}
+
+				p.objectEntry(key, expr.Elements[key])
+			}
+		}
+		p.print("]")
+	case *ast.TemplateLiteral:
+		p.template(expr.Components, expr.Expressions)
+	case *ast.CommandSubstitution:
+		p.subst(expr)
+	}
+}
+
+// subst prints the command substitution c.
+func (p *printer) subst(c *ast.CommandSubstitution) {
+	p.print(c.Token.Literal)
+	p.command(c.Command)
+	p.print(c.Closing.Literal)
+}
+
+func (p *printer) expressionList(list []ast.Expression) {
+	for i, expr := range list {
+		if i > 0 {
+			p.print(", ")
+		}
+
+		p.expression(expr)
+	}
+}
+
+func (p *printer) parameters(fn *ast.FunctionLiteral) {
+	p.print("(")
+	for i, param := range fn.Parameters {
+		if i > 0 {
+			p.print(", ")
+		}
+
+		p.print(param.Literal)
+	}
+
+	if fn.Variadic {
+		p.print("...")
+	}
+	p.print(")")
+}
+
+// multiline reports whether the composite literal node spans multiple
+// lines in the source, in which case it is printed with one entry on each
+// line.
+func (p *printer) multiline(node ast.Node) bool {
+	return p.lineOf(node.Pos()) != p.lineOf(node.End())
+}
+
+// entries prints the entries of a composite literal, each on it's own
+// line and followed by a comma. The ith entry is printed by the entry
+// function, and starts at first[i] and ends at last[i] in the source. The
+// position of the closing bracket of the literal is closing.
+func (p *printer) entries(first, last []ast.Node, closing token.Pos, entry func(i int)) {
+	p.line = 0
+	p.newline()
+
+	p.indent++
+	for i := range first {
+		p.commentsBefore(first[i].Pos())
+
+		p.startLine(p.lineOf(first[i].Pos()))
+		entry(i)
+		p.print(",")
+		p.trailingComment(p.lineOf(last[i].End()))
+
+		p.line = p.lineOf(last[i].End())
+		p.newline()
+	}
+
+	p.commentsBefore(closing)
+	p.indent--
+
+	p.print(strings.Repeat("\t", p.indent))
+}
+
+// objectKeys returns the keys of the object literal obj, in the order in
+// which they are present in the source.
+func objectKeys(obj *ast.ObjectLiteral) []ast.Expression {
+	keys := make([]ast.Expression, 0, len(obj.Elements))
+	for key := range obj.Elements {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Pos() < keys[j].Pos()
+	})
+
+	return keys
+}
+
+func (p *printer) objectEntry(key, value ast.Expression) {
+	p.expression(key)
+	p.print(": ")
+	p.expression(value)
+}
+
+// template prints a template literal with the string components and
+// embedded expressions exprs.
+func (p *printer) template(components []token.Token, exprs []ast.Expression) {
+	p.print("'")
+	for i, c := range components {
+		if c.Type == token.String {
+			p.print(c.Literal)
+		}
+
+		if i < len(exprs) {
+			p.print("{")
+			p.expression(exprs[i])
+			p.print("}")
+		}
+	}
+	p.print("'")
+}
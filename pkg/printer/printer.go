@@ -0,0 +1,134 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package printer implements the printing of mash syntax trees as
+// canonically formatted mash source.
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+// printer represents the state of the printing of a syntax tree.
+type printer struct {
+	fset   *token.FileSet // positions of the printed nodes
+	buf    bytes.Buffer
+	indent int // current indentation level
+
+	comments []*ast.Comment // comments which have not been printed
+	heredocs []*ast.Heredoc // heredocs whose bodies have not been printed
+	line     int            // source line of the last printed node
+}
+
+// Fprint formats the syntax tree node in the canonical mash style, and
+// writes it to w. The positions of the node are resolved with the file set
+// fset. The comments of a Program node are printed along with it's
+// statements, at their original positions.
+func Fprint(w io.Writer, fset *token.FileSet, node ast.Node) error {
+	p := &printer{fset: fset}
+
+	switch n := node.(type) {
+	case *ast.Program:
+		for _, group := range n.Comments {
+			p.comments = append(p.comments, group.List...)
+		}
+
+		p.statements(n.Statements, token.NoPos)
+	case ast.Statement:
+		p.statement(n)
+	case ast.Expression:
+		p.expression(n)
+	case ast.Command:
+		p.command(n)
+	case ast.CommandComponent:
+		p.component(n)
+	default:
+		return fmt.Errorf("printer: unsupported node type %T", node)
+	}
+
+	if len(p.heredocs) > 0 {
+		p.newline()
+	}
+
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+func (p *printer) print(a ...string) {
+	for _, s := range a {
+		p.buf.WriteString(s)
+	}
+}
+
+// newline ends the current line, and prints the bodies of the heredocs
+// which were started in it.
+func (p *printer) newline() {
+	p.print("\n")
+
+	for _, h := range p.heredocs {
+		p.heredocBody(h)
+		p.line = p.lineOf(h.Terminator.Pos)
+	}
+
+	p.heredocs = nil
+}
+
+// lineOf returns the source line of the position pos.
+func (p *printer) lineOf(pos token.Pos) int {
+	return p.fset.Position(pos).Line
+}
+
+// startLine starts a new indented line for a node at the source line
+// line, preserving a single empty line from the source before it.
+func (p *printer) startLine(line int) {
+	if p.line > 0 && line > p.line+1 {
+		p.print("\n")
+	}
+
+	p.print(strings.Repeat("\t", p.indent))
+}
+
+// before reports whether the position a is before the position b. NoPos
+// is after every other position.
+func before(a, b token.Pos) bool {
+	return b == token.NoPos || a < b
+}
+
+// commentsBefore prints the comments which are before pos, each on their
+// own line.
+func (p *printer) commentsBefore(pos token.Pos) {
+	for len(p.comments) > 0 && before(p.comments[0].Pos(), pos) {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+
+		p.startLine(p.lineOf(c.Pos()))
+		p.print(c.Token.Literal)
+		p.newline()
+		p.line = p.lineOf(c.Pos())
+	}
+}
+
+// trailingComment prints the next comment after the current node, if it
+// is on the source line line, where the node ends.
+func (p *printer) trailingComment(line int) {
+	if len(p.comments) > 0 && p.lineOf(p.comments[0].Pos()) == line {
+		p.print(" ", p.comments[0].Token.Literal)
+		p.comments = p.comments[1:]
+	}
+}
@@ -0,0 +1,74 @@
+package printer
+
+import (
+	"bytes"
+	"testing"
+
+	"laptudirm.com/x/mash/pkg/parser"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+func format(t *testing.T, src string) string {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	program, err := parser.Parse(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("%q: %s", src, err)
+	}
+
+	var buf bytes.Buffer
+	if err := Fprint(&buf, fset, program); err != nil {
+		t.Fatalf("%q: %s", src, err)
+	}
+
+	return buf.String()
+}
+
+func TestFprint(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"let   x=1+2*(3-1)", "let x = 1 + 2 * (3 - 1)\n"},
+		{"let x+=-a.b[1]", "let x += -a.b[1]\n"},
+		{"let x=0x_fF+017*1.5e3", "let x = 0x_fF + 017 * 1.5e3\n"},
+		{"let x = f( 1,'a{b}c' ) || !y && z", "let x = f(1, 'a{b}c') || !y && z\n"},
+		{`let o = obj["a":1,"b":  [1,2]]`, "let o = obj[\"a\": 1, \"b\": [1, 2]]\n"},
+		{
+			"let o = obj[\"b\": 1,\n\"a\": [\n1, # one\n]]",
+			"let o = obj[\n\t\"b\": 1,\n\t\"a\": [\n\t\t1, # one\n\t],\n]\n",
+		},
+		{"let f = func {}\nlet g = func(a){return a}", "let f = func {}\nlet g = func(a) {\n\treturn a\n}\n"},
+		{"func f(a,rest...){\n\n\nlet x = 1\n\n\n\nlet y = 2\n}", "func f(a, rest...) {\n\tlet x = 1\n\n\tlet y = 2\n}\n"},
+		{"if a {\nlet x = 1\n} else if b {\n} else {\nlet x = 2\n}", "if a {\n\tlet x = 1\n} else if b {} else {\n\tlet x = 2\n}\n"},
+		{"for {\nbreak\n}\nfor x < 1 { continue }", "for {\n\tbreak\n}\nfor x < 1 {\n\tcontinue\n}\n"},
+		{"echo a  'b{c}'   2>&1   >>log <in", "echo a 'b{c}' 2>&1 >>log <in\n"},
+		{"! echo a   |   cat &&   true ||false", "! echo a | cat && true || false\n"},
+		{"echo   $A/b  \"$A ${B?} \\$C\"'{x}'$D  >$E", "echo $A/b \"$A ${B?} \\$C\"'{x}'$D >$E\n"},
+		{"cp   ~/a/{b,c{1..3},}.yml  ~user/{x..z}", "cp ~/a/{b,c{1..3},}.yml ~user/{x..z}\n"},
+		{"let x = $(git  log | head)+$?( ls )\necho a$(pwd)  &", "let x = $(git log | head) + $?(ls)\necho a$(pwd) &\n"},
+		{"(  echo a ;echo b )|cat  >out\n(\nlet x = 1\npwd\n)", "(echo a; echo b) | cat >out\n(\n\tlet x = 1\n\tpwd\n)\n"},
+		{"(echo a # a\n) 2>&1", "(\n\techo a # a\n) 2>&1\n"},
+		{"cat <<-EOF | cat\n\tbody\n\tEOF\necho", "cat <<-EOF | cat\n\tbody\n\tEOF\necho\n"},
+		{"cat <<'EOF' # comment\nx {1+2}\nEOF", "cat <<'EOF' # comment\nx {1 + 2}\nEOF\n"},
+		{
+			"# head\n\n\n# doc\nlet x = 1 # x\nif x { # if\n\t# inner\n\tlet y = 2\n\t# end\n} # after\n# tail",
+			"# head\n\n# doc\nlet x = 1 # x\nif x { # if\n\t# inner\n\tlet y = 2\n\t# end\n} # after\n# tail\n",
+		},
+		{"let g = func(a) { return a } # g", "let g = func(a) {\n\treturn a\n} # g\n"},
+	}
+
+	for _, test := range tests {
+		got := format(t, test.src)
+		if got != test.want {
+			t.Errorf("%q: expected\n%s\nreceived\n%s", test.src, test.want, got)
+			continue
+		}
+
+		// formatting must be idempotent
+		if again := format(t, got); again != got {
+			t.Errorf("%q: formatting is not idempotent, received\n%s", test.src, again)
+		}
+	}
+}
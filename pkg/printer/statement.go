@@ -0,0 +1,137 @@
+// Copyright © 2022 Rak Laptudirm <raklaptudirm@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package printer
+
+import (
+	"strings"
+
+	"laptudirm.com/x/mash/pkg/ast"
+	"laptudirm.com/x/mash/pkg/token"
+)
+
+// statements prints a list of statements, one on each line, followed by
+// the comments which are before the position end.
+func (p *printer) statements(list []ast.Statement, end token.Pos) {
+	for i, stmt := range list {
+		p.commentsBefore(stmt.Pos())
+
+		p.startLine(p.lineOf(stmt.Pos()))
+		p.statement(stmt)
+
+		// comment may be after the end of the list, on the same line
+		next := end
+		if i+1 < len(list) {
+			next = list[i+1].Pos()
+		}
+
+		if p.hasComments(next) {
+			p.trailingComment(p.lineOf(stmt.End()))
+		}
+
+		p.line = p.lineOf(stmt.End())
+		p.newline()
+	}
+
+	p.commentsBefore(end)
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.print("let ")
+		p.expression(stmt.Expression)
+	case *ast.FuncStatement:
+		p.print("func ", stmt.Name.Literal)
+		p.parameters(stmt.Function)
+		p.print(" ")
+		p.block(stmt.Function.Block)
+	case *ast.IfStatement:
+		p.print("if ")
+		p.expression(stmt.Condition)
+		p.print(" ")
+		p.block(stmt.BlockStmt)
+
+		if stmt.ElseBlock != nil {
+			p.print(" else ")
+			p.statement(stmt.ElseBlock)
+		}
+	case *ast.ForStatement:
+		p.print("for ")
+		if stmt.Condition != nil {
+			p.expression(stmt.Condition)
+			p.print(" ")
+		}
+
+		p.block(stmt.BlockStmt)
+	case *ast.BreakStatement:
+		p.print("break")
+	case *ast.ContinueStatement:
+		p.print("continue")
+	case *ast.ReturnStatement:
+		p.print("return")
+		if stmt.Result != nil {
+			p.print(" ")
+			p.expression(stmt.Result)
+		}
+	case *ast.BlockStatement:
+		p.block(stmt)
+	case *ast.CmdStatement:
+		p.command(stmt.Command)
+		if stmt.Background.Type == token.And {
+			p.print(" ", stmt.Background.Literal)
+		}
+	}
+}
+
+// block prints a block of statements. Empty blocks are printed as {}.
+func (p *printer) block(b *ast.BlockStatement) {
+	p.enclosed("{", "}", b.Opening.Pos, b.Statements, b.Closing.Pos)
+}
+
+// enclosed prints a list of statements, which are enclosed by the opening
+// delimiter open at the position opening and the closing delimiter close
+// at the position closing, with each statement on it's own line. Empty
+// lists are printed on a single line.
+func (p *printer) enclosed(open, close string, opening token.Pos, list []ast.Statement, closing token.Pos) {
+	if len(list) == 0 && !p.hasComments(closing) {
+		p.print(open, close)
+		return
+	}
+
+	// comment after the opening delimiter, not after the statements
+	next := closing
+	if len(list) > 0 {
+		next = list[0].Pos()
+	}
+
+	p.print(open)
+	if p.hasComments(next) {
+		p.trailingComment(p.lineOf(opening))
+	}
+	// no empty lines at the start of the list
+	p.line = 0
+	p.newline()
+
+	p.indent++
+	p.statements(list, closing)
+	p.indent--
+
+	p.print(strings.Repeat("\t", p.indent), close)
+}
+
+// hasComments reports whether any of the unprinted comments are before
+// the position pos.
+func (p *printer) hasComments(pos token.Pos) bool {
+	return len(p.comments) > 0 && before(p.comments[0].Pos(), pos)
+}
@@ -13,22 +13,233 @@
 
 package token
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
 
-// Position represents a specific line and column in a source string.
+// Position represents a resolved position in a source file.
 type Position struct {
-	Line int
-	Col  int
+	Filename string // name of the file, if any
+	Offset   int    // byte offset, starting at 0
+	Line     int    // line number, starting at 1
+	Col      int    // column number in runes, starting at 1
 }
 
-// String returns a string representation of p, in the format line:column.
+// IsValid reports whether p is a valid position.
+func (p *Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns a string representation of p, in one of the formats:
+//
+//	file:line:column    valid position with a file name
+//	line:column         valid position without a file name
+//	file                invalid position with a file name
+//	-                   invalid position without a file name
 func (p *Position) String() string {
-	return fmt.Sprintf("%v:%v", p.Line, p.Col)
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+
+		s += fmt.Sprintf("%d:%d", p.Line, p.Col)
+	}
+
+	if s == "" {
+		s = "-"
+	}
+
+	return s
+}
+
+// Pos is a compact encoding of a position in a file of a FileSet. It can
+// be converted into a Position with the FileSet. Pos values of the same
+// file can be compared to find out which one is earlier in the source.
+type Pos int
+
+// NoPos is the zero Pos, which isn't associated with any file or position.
+const NoPos Pos = 0
+
+// IsValid reports whether p is a valid position.
+func (p Pos) IsValid() bool {
+	return p != NoPos
+}
+
+// File represents a source file in a FileSet. Pos values of the file lie
+// in the range [base, base+size].
+type File struct {
+	name string
+	base int
+	src  string
+
+	lines []int // offsets of the first byte of each line
+}
+
+// Name returns the name of the file f.
+func (f *File) Name() string {
+	return f.name
+}
+
+// Base returns the Pos value of the first byte of the file f.
+func (f *File) Base() int {
+	return f.base
+}
+
+// Size returns the size of the file f in bytes.
+func (f *File) Size() int {
+	return len(f.src)
+}
+
+// Source returns the source of the file f.
+func (f *File) Source() string {
+	return f.src
+}
+
+// LineCount returns the number of lines in the file f.
+func (f *File) LineCount() int {
+	return len(f.lines)
+}
+
+// Pos returns the Pos value of the byte offset in the file f. It panics
+// if the offset is larger than the size of the file.
+func (f *File) Pos(offset int) Pos {
+	if offset < 0 || offset > f.Size() {
+		panic(fmt.Sprintf("invalid file offset %d (should be <= %d)", offset, f.Size()))
+	}
+
+	return Pos(f.base + offset)
+}
+
+// Offset returns the byte offset of the Pos value p in the file f. It
+// panics if p doesn't lie in the file.
+func (f *File) Offset(p Pos) int {
+	if !f.contains(p) {
+		panic(fmt.Sprintf("invalid Pos value %d (should be in [%d, %d])", p, f.base, f.base+f.Size()))
+	}
+
+	return int(p) - f.base
 }
 
-// NextLine emulates going to the next line from position p in a string by
-// increasing line by 1 and setting column to 1, or the first column.
-func (p *Position) NextLine() {
-	p.Line++
-	p.Col = 1
+// contains reports whether the Pos value p lies in the file f.
+func (f *File) contains(p Pos) bool {
+	return f.base <= int(p) && int(p) <= f.base+f.Size()
+}
+
+// Line returns the line number of the Pos value p in the file f.
+func (f *File) Line(p Pos) int {
+	return f.Position(p).Line
+}
+
+// Position returns the resolved Position of the Pos value p in the file
+// f, or an invalid position for NoPos. The column of the position counts
+// runes, not bytes.
+func (f *File) Position(p Pos) Position {
+	if p == NoPos {
+		return Position{}
+	}
+
+	offset := f.Offset(p)
+
+	// index of the last line starting at or before offset
+	i := sort.SearchInts(f.lines, offset+1) - 1
+	start := f.lines[i]
+
+	return Position{
+		Filename: f.name,
+		Offset:   offset,
+		Line:     i + 1,
+		Col:      utf8.RuneCountInString(f.src[start:offset]) + 1,
+	}
+}
+
+// FileSet represents a set of source files. The Pos values of the files
+// don't overlap, so a Pos value identifies both a file and an offset in
+// it. A FileSet is safe for concurrent use.
+type FileSet struct {
+	mutex sync.Mutex
+	base  int     // base of the next file
+	files []*File // files in the order of their bases
+	last  *File   // file of the last Position lookup
+}
+
+// NewFileSet creates a new, empty file set.
+func NewFileSet() *FileSet {
+	return &FileSet{
+		base: 1, // 0 is NoPos
+	}
+}
+
+// Base returns the base of the next file added to the file set s.
+func (s *FileSet) Base() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.base
+}
+
+// AddFile adds a new file with the name filename and the source src to
+// the file set s, and returns it. The Pos values of the file start at the
+// set's current base, which is then moved past the end of the file.
+func (s *FileSet) AddFile(filename, src string) *File {
+	f := &File{
+		name:  filename,
+		src:   src,
+		lines: []int{0},
+	}
+
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			f.lines = append(f.lines, i+1)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	f.base = s.base
+	// the end of the file has a Pos value, so leave a gap of one byte
+	s.base += len(src) + 1
+	s.files = append(s.files, f)
+	return f
+}
+
+// File returns the file of the file set s which contains the Pos value
+// p, or nil if there is no such file.
+func (s *FileSet) File(p Pos) *File {
+	if p == NoPos {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if f := s.last; f != nil && f.contains(p) {
+		return f
+	}
+
+	// index of the last file starting at or before p
+	i := sort.Search(len(s.files), func(i int) bool {
+		return s.files[i].base > int(p)
+	}) - 1
+
+	if i < 0 || !s.files[i].contains(p) {
+		return nil
+	}
+
+	s.last = s.files[i]
+	return s.last
+}
+
+// Position returns the resolved Position of the Pos value p in the file
+// set s, or an invalid position if p doesn't lie in any of its files.
+func (s *FileSet) Position(p Pos) Position {
+	if f := s.File(p); f != nil {
+		return f.Position(p)
+	}
+
+	return Position{}
 }
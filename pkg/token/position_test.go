@@ -0,0 +1,56 @@
+package token
+
+import "testing"
+
+func TestFileSet(t *testing.T) {
+	fset := NewFileSet()
+	a := fset.AddFile("a.mash", "let x = 1\nlet s = \"é\" + x\n")
+	b := fset.AddFile("b.mash", "echo b")
+
+	tests := []struct {
+		file   *File
+		offset int
+		want   string
+	}{
+		{a, 0, "a.mash:1:1"},
+		{a, 9, "a.mash:1:10"},
+		{a, 10, "a.mash:2:1"},
+		{a, 21, "a.mash:2:11"}, // after the 2 byte é
+		{a, 27, "a.mash:3:1"},  // end of file
+		{b, 0, "b.mash:1:1"},
+		{b, 6, "b.mash:1:7"},
+	}
+
+	for _, test := range tests {
+		p := test.file.Pos(test.offset)
+		pos := fset.Position(p)
+
+		if got := pos.String(); got != test.want {
+			t.Errorf("%s offset %d: expected %s, received %s", test.file.Name(), test.offset, test.want, got)
+		}
+
+		if pos.Offset != test.offset || test.file.Offset(p) != test.offset {
+			t.Errorf("%s offset %d: received offset %d", test.file.Name(), test.offset, pos.Offset)
+		}
+
+		if fset.File(p) != test.file {
+			t.Errorf("%s offset %d: resolved to the wrong file", test.file.Name(), test.offset)
+		}
+	}
+
+	if a.LineCount() != 3 || b.LineCount() != 1 {
+		t.Errorf("expected 3 and 1 lines, received %d and %d", a.LineCount(), b.LineCount())
+	}
+
+	if b.Base() != a.Base()+a.Size()+1 || fset.Base() != b.Base()+b.Size()+1 {
+		t.Errorf("overlapping file bases %d, %d, and %d", a.Base(), b.Base(), fset.Base())
+	}
+
+	if pos := fset.Position(NoPos); pos.IsValid() || pos.String() != "-" {
+		t.Errorf("expected invalid position for NoPos, received %s", &pos)
+	}
+
+	if f := fset.File(Pos(fset.Base())); f != nil {
+		t.Errorf("expected no file after the last file, received %s", f.Name())
+	}
+}
@@ -31,8 +31,13 @@ const (
 	literalBeg
 	// Identifiers and basic type literals
 	Identifier // main
-	Number     // 3.14
+	Int        // 12345
+	Float      // 123.45
 	String     // "abc"
+	EnvVar     // $HOME
+
+	HeredocBody // text of a heredoc
+	HeredocEnd  // terminator of a heredoc
 	literalEnd
 
 	operatorBeg
@@ -83,12 +88,23 @@ const (
 	Template  // '
 	Comma     // ,
 	Period    // .
+	Ellipsis  // ...
 
 	RightParen // )
 	RightBrack // ]
 	RightBrace // }
 	Semicolon  // ;
 	Colon      // :
+
+	RedirectIn     // <
+	RedirectOut    // >
+	RedirectAppend // >>
+	RedirectAll    // &>
+	RedirectDup    // >&
+	HereString     // <<<
+	Heredoc        // <<EOF
+	Subst          // $(
+	SubstResult    // $?(
 	operatorEnd
 
 	keywordBeg
@@ -114,8 +130,13 @@ var tokens = [...]string{
 	Comment: "COMMENT",
 
 	Identifier: "IDENT",
-	Number:     "FLOAT",
+	Int:        "INT",
+	Float:      "FLOAT",
 	String:     "STRING",
+	EnvVar:     "ENV",
+
+	HeredocBody: "HEREDOC",
+	HeredocEnd:  "HEREDOC_END",
 
 	Addition:       "+",
 	Subtraction:    "-",
@@ -163,6 +184,7 @@ var tokens = [...]string{
 	Template:  "'",
 	Comma:     ",",
 	Period:    ".",
+	Ellipsis:  "...",
 
 	RightParen: ")",
 	RightBrack: "]",
@@ -170,6 +192,16 @@ var tokens = [...]string{
 	Semicolon:  ";",
 	Colon:      ":",
 
+	RedirectIn:     "<",
+	RedirectOut:    ">",
+	RedirectAppend: ">>",
+	RedirectAll:    "&>",
+	RedirectDup:    ">&",
+	HereString:     "<<<",
+	Heredoc:        "<<",
+	Subst:          "$(",
+	SubstResult:    "$?(",
+
 	For:  "for",
 	If:   "if",
 	Else: "else",
@@ -302,7 +334,13 @@ func Lookup(name string) Type {
 
 // Token represtents a single token which will be emitted by the lexer.
 type Token struct {
-	Type     Type     // type of the token
-	Literal  string   // literal in source
-	Position Position // position in source
+	Type    Type   // type of the token
+	Literal string // literal in source
+	Pos     Pos    // position in source
+}
+
+// End returns the position in the source of the character immediately
+// after the token.
+func (t Token) End() Pos {
+	return t.Pos + Pos(len(t.Literal))
 }